@@ -1,12 +1,20 @@
 package main
 
 import (
+	"time"
+
 	"github.com/eterline/x3ui-exporter/internal/app"
 	"github.com/eterline/x3ui-exporter/internal/config"
 	"github.com/eterline/x3ui-exporter/pkg/logger"
 	"github.com/eterline/x3ui-exporter/pkg/toolkit"
 )
 
+// set by goreleaser ldflags
+var (
+	version = "dev"
+	commit  = "none"
+)
+
 var (
 	cfg = config.Configuration{
 		LogDir:    "./logs",
@@ -21,10 +29,82 @@ var (
 		DashboardBase:     "",
 		DashboardLogin:    "",
 		DashboardPassword: "",
+
+		Source:        "api",
+		SourceDB:      "/etc/x-ui/x-ui.db",
+		SourceFixture: "",
+		XrayAPI:       "",
+		XrayConfig:    "/usr/local/x-ui/bin/config.json",
+
+		Namespace:     "xui",
+		LegacyMetrics: false,
+
+		IdentityMode:    "raw",
+		IdentitySalt:    "",
+		IdentityAliases: "",
+		IdentityToken:   "",
+		IdentityListen:  "127.0.0.1:4501",
+
+		RelabelConfig: "",
+
+		GroupBy:        "none",
+		GroupSeparator: "-",
+		GroupMapping:   "",
+		NoClientSeries: false,
+
+		PushStaleAfter: 5 * time.Minute,
+		DropStalePush:  false,
+		TrustedProxies: []string{},
+
+		HistoryDB:      "",
+		HistoryHourly:  7 * 24 * time.Hour,
+		HistoryDaily:   400 * 24 * time.Hour,
+		HistoryMonthly: 0,
+
+		AccessLog:    "",
+		AccessLogTop: 20,
+		IPWindows:    []time.Duration{5 * time.Minute, time.Hour, 24 * time.Hour},
+		PanelIPPoll:  0,
+
+		GeoIPCountry: "",
+		GeoIPASN:     "",
+		GeoIPClients: false,
+
+		WebhookURLs:     []string{},
+		WebhookTemplate: "",
+		NotifyRetries:   3,
+		QuotaThresholds: []float64{0.8, 1},
+		ExpiryWarn:      3 * 24 * time.Hour,
+
+		TelegramToken:    "",
+		TelegramChats:    []string{},
+		TelegramAPI:      "https://api.telegram.org",
+		TelegramTemplate: "",
+		TelegramInterval: time.Second,
+
+		AnomalyFactor:   0,
+		AnomalyMinRate:  1 << 20,
+		AnomalyAbsolute: 0,
+		AnomalyWindow:   6 * time.Hour,
+
+		RemoteMode:       "",
+		RemoteURL:        "",
+		RemoteInterval:   30 * time.Second,
+		RemoteJob:        "3xui-exporter",
+		RemoteInstance:   "",
+		RemoteUsername:   "",
+		RemotePassword:   "",
+		RemoteBearer:     "",
+		RemoteRetries:    3,
+		RemoteBuffer:     "",
+		RemoteBufferSize: 64 << 20,
 	}
 )
 
 func main() {
+	cfg.BuildVersion = version
+	cfg.BuildCommit = commit
+
 	root := toolkit.InitAppStart(func() error {
 		var err error
 
@@ -42,5 +122,20 @@ func main() {
 		return err
 	})
 
+	if cfg.Report != nil {
+		app.Report(cfg, root)
+		return
+	}
+
+	if cfg.Rules != nil {
+		app.Rules(cfg)
+		return
+	}
+
+	if cfg.GrafanaDashboard != nil {
+		app.Dashboard(cfg)
+		return
+	}
+
 	app.Execute(cfg, root)
 }
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/eterline/x3ui-exporter/internal/config"
+	"github.com/eterline/x3ui-exporter/internal/service/accesslog"
+	"github.com/eterline/x3ui-exporter/internal/service/geoip"
+	"github.com/eterline/x3ui-exporter/internal/service/metrics"
+	"github.com/eterline/x3ui-exporter/internal/service/scrape"
+	x3uiapi "github.com/eterline/x3ui-exporter/pkg/x3-ui-api"
+	"github.com/prometheus/common/model"
+)
+
+func processAccessLog(ctx context.Context, t *accesslog.Tailer, st *accesslog.Stats, geo *geoip.Resolver, reg *metrics.MetricsReg) {
+	t.Run(ctx, func(line string) {
+		e, ok := accesslog.Parse(line)
+		if !ok {
+			return
+		}
+
+		reg.ObserveConnection(e.Email, e.Accepted)
+		st.Observe(e, time.Now())
+
+		if geo != nil && e.Accepted {
+			loc := geo.Lookup(e.Source)
+			reg.ObserveGeoConnection(e.Email, loc.Country, loc.ASN, loc.ASOrg)
+		}
+	}, func(err error) {
+		log.Errorf("access log tail error: %v", err)
+	})
+}
+
+// accessEnabled - access log or panel client IPs feed access stats
+func accessEnabled(cfg config.Configuration) bool {
+	return cfg.AccessLog != "" || cfg.PanelIPPoll > 0
+}
+
+// accessSinks - scrape consumers of access stats, none if access stats are disabled
+func accessSinks(cfg config.Configuration, st *accesslog.Stats) []scrapeSink {
+	if !accessEnabled(cfg) {
+		return nil
+	}
+	return []scrapeSink{clientLimitSink(st)}
+}
+
+// clientLimitSink - updates client IP limits from scraped client stats
+func clientLimitSink(st *accesslog.Stats) scrapeSink {
+	return func(_ []scrape.InboundStat, stats []scrape.ClientStat) {
+		limits := map[string]int{}
+		for _, cl := range stats {
+			if cl.LimitIP > 0 {
+				limits[cl.Email] = cl.LimitIP
+			}
+		}
+		st.SetLimits(limits)
+	}
+}
+
+// panelIPs - accounts client IPs recorded by panel.
+// Panel keeps recorded IPs until they are cleared and has no reliable last seen time,
+// so only IPs appeared since previous poll are seen now. IPs listed on first poll
+// have unknown age and are only remembered, access log accounts them once they connect
+type panelIPs struct {
+	st    *accesslog.Stats
+	known map[string]map[string]struct{}
+}
+
+func (p *panelIPs) observe(ips x3uiapi.ClientIPs, now time.Time) {
+	listed := make(map[string]map[string]struct{}, len(ips))
+
+	for email, list := range ips {
+		listed[email] = make(map[string]struct{}, len(list))
+
+		for _, ip := range list {
+			listed[email][ip] = struct{}{}
+
+			if _, ok := p.known[email][ip]; ok || p.known == nil {
+				continue
+			}
+			p.st.ObserveIP(email, ip, now)
+		}
+	}
+	p.known = listed
+}
+
+// processClientIPs - polls client IPs recorded by panel apart from scrapes,
+// panel API answers them with one request per client
+func processClientIPs(ctx context.Context, scr *scrape.ScraperXUI, st *accesslog.Stats, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	p := &panelIPs{st: st}
+
+	for {
+		ips, err := scr.ScrapeClientIPs()
+		switch {
+		case err == nil:
+			p.observe(ips, time.Now())
+
+		case errors.Is(err, x3uiapi.ErrNotSupported):
+			log.Warn("panel data source does not record client IPs, polling stopped")
+			return
+
+		default:
+			log.Error(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processAccessStats - periodically exports client IPs and top destinations
+func processAccessStats(ctx context.Context, st *accesslog.Stats, reg *metrics.MetricsReg) {
+	ticker := time.NewTicker(scrapeDuration)
+	defer ticker.Stop()
+
+	windows := make([]string, 0, len(st.Windows()))
+	for _, w := range st.Windows() {
+		windows = append(windows, model.Duration(w).String())
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			top := map[string]uint64{}
+			for _, d := range st.TopDestinations() {
+				top[d.Host] = d.Count
+			}
+
+			clients := []metrics.ClientIPs{}
+			for _, cl := range st.SourceIPs(time.Now()) {
+				counts := make(map[string]int, len(windows))
+				for i, w := range windows {
+					counts[w] = cl.Counts[i]
+				}
+				clients = append(clients, metrics.ClientIPs{Email: cl.Email, Windows: counts, Limit: cl.Limit})
+			}
+
+			reg.SetClientSourceIPs(clients)
+			reg.SetTopDestinations(top)
+			reg.Publish()
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eterline/x3ui-exporter/internal/config"
+	"github.com/eterline/x3ui-exporter/internal/service/accesslog"
+	"github.com/eterline/x3ui-exporter/internal/service/scrape"
+	x3uiapi "github.com/eterline/x3ui-exporter/pkg/x3-ui-api"
+)
+
+// ipSource - panel source counting client IPs requests
+type ipSource struct {
+	scrape.FixtureSource
+	calls atomic.Int32
+	ips   x3uiapi.ClientIPs
+}
+
+func (s *ipSource) ClientIPs(context.Context) (x3uiapi.ClientIPs, error) {
+	s.calls.Add(1)
+	return s.ips, nil
+}
+
+var limitedStats = []scrape.ClientStat{
+	{Name: "vless", Email: "alice", LimitIP: 2},
+	{Name: "vless", Email: "bob"},
+}
+
+func TestAccessSinksDisabled(t *testing.T) {
+	st := accesslog.NewStats(10, []time.Duration{time.Hour})
+
+	if sinks := accessSinks(config.Configuration{}, st); len(sinks) != 0 {
+		t.Errorf("accessSinks() without access log and panel IPs = %d sinks, want none", len(sinks))
+	}
+	if accessEnabled(config.Configuration{}) {
+		t.Error("access stats enabled without access log and panel IPs")
+	}
+}
+
+func TestAccessSinksLimits(t *testing.T) {
+	src := &ipSource{ips: x3uiapi.ClientIPs{"alice": {"198.51.100.1"}}}
+	st := accesslog.NewStats(10, []time.Duration{time.Hour})
+
+	sinks := accessSinks(config.Configuration{AccessLog: "access.log"}, st)
+	if len(sinks) == 0 {
+		t.Fatal("accessSinks() with access log = none")
+	}
+	for _, sink := range sinks {
+		sink(nil, limitedStats)
+	}
+
+	// scrape sinks never ask panel for client IPs
+	if n := src.calls.Load(); n != 0 {
+		t.Errorf("client IPs requests during scrape = %d, want 0", n)
+	}
+
+	clients := st.SourceIPs(time.Now())
+	if len(clients) != 1 || clients[0].Email != "alice" || clients[0].Limit != 2 {
+		t.Errorf("SourceIPs() = %+v, want alice limit 2", clients)
+	}
+}
+
+func TestPanelIPs(t *testing.T) {
+	st := accesslog.NewStats(10, []time.Duration{time.Hour})
+	p := &panelIPs{st: st}
+	now := time.Now()
+
+	// first poll lists IPs of unknown age, they are only remembered
+	p.observe(x3uiapi.ClientIPs{"alice": {"198.51.100.1", "198.51.100.2"}}, now)
+	if clients := st.SourceIPs(now); len(clients) != 0 {
+		t.Fatalf("SourceIPs() after first poll = %+v, want none", clients)
+	}
+
+	p.observe(x3uiapi.ClientIPs{
+		"alice": {"198.51.100.1", "198.51.100.2", "198.51.100.3"},
+		"bob":   {"203.0.113.9"},
+	}, now)
+
+	got := map[string]int{}
+	for _, cl := range st.SourceIPs(now) {
+		got[cl.Email] = cl.Counts[0]
+	}
+	if got["alice"] != 1 || got["bob"] != 1 {
+		t.Errorf("SourceIPs() after second poll = %v, want only new IPs", got)
+	}
+}
+
+func TestProcessClientIPs(t *testing.T) {
+	src := &ipSource{ips: x3uiapi.ClientIPs{"alice": {"198.51.100.1"}}}
+	st := accesslog.NewStats(10, []time.Duration{time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		processClientIPs(ctx, scrape.NewScraperXUI(ctx, src), st, 5*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for src.calls.Load() < 3 {
+		select {
+		case <-deadline:
+			t.Fatalf("client IPs polls = %d, want 3", src.calls.Load())
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	cancel()
+	<-done
+}
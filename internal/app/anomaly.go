@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/eterline/x3ui-exporter/internal/service/anomaly"
+	"github.com/eterline/x3ui-exporter/internal/service/metrics"
+	"github.com/eterline/x3ui-exporter/internal/service/notify"
+)
+
+// processAnomalies - periodically exports client anomaly scores and publishes started anomalies
+func processAnomalies(ctx context.Context, d *anomaly.Detector, panel string, reg *metrics.MetricsReg, publish func([]notify.Event)) {
+	ticker := time.NewTicker(scrapeDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			scores, anomalies := d.Evaluate(time.Now())
+
+			samples := make([]metrics.AnomalyScore, 0, len(scores))
+			for _, sc := range scores {
+				samples = append(samples, metrics.AnomalyScore{
+					Email:    sc.Email,
+					Rate:     sc.Rate,
+					Baseline: sc.Baseline,
+					Score:    sc.Value,
+				})
+			}
+			reg.SetAnomalyScores(samples)
+			reg.Publish()
+
+			events := make([]notify.Event, 0, len(anomalies))
+			for _, a := range anomalies {
+				log.Warnf("client %s traffic anomaly: %.0f B/s, baseline %.0f B/s", a.Email, a.Rate, a.Baseline)
+				events = append(events, notify.AnomalyEvent(panel, a.Email, a.Rate, a.Baseline, a.Time))
+			}
+			publish(events)
+		}
+	}
+}
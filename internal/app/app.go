@@ -2,16 +2,31 @@ package app
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"net/url"
+	"os"
 	"time"
 
 	"github.com/eterline/x3ui-exporter/internal/config"
 	"github.com/eterline/x3ui-exporter/internal/server"
+	"github.com/eterline/x3ui-exporter/internal/service/accesslog"
+	"github.com/eterline/x3ui-exporter/internal/service/anomaly"
+	"github.com/eterline/x3ui-exporter/internal/service/api"
+	"github.com/eterline/x3ui-exporter/internal/service/geoip"
+	"github.com/eterline/x3ui-exporter/internal/service/group"
+	"github.com/eterline/x3ui-exporter/internal/service/history"
+	"github.com/eterline/x3ui-exporter/internal/service/identity"
 	"github.com/eterline/x3ui-exporter/internal/service/metrics"
+	"github.com/eterline/x3ui-exporter/internal/service/notify"
+	"github.com/eterline/x3ui-exporter/internal/service/pusher"
 	"github.com/eterline/x3ui-exporter/internal/service/scrape"
+	"github.com/eterline/x3ui-exporter/internal/service/state"
 	"github.com/eterline/x3ui-exporter/pkg/logger"
+	"github.com/eterline/x3ui-exporter/pkg/relabel"
 	"github.com/eterline/x3ui-exporter/pkg/toolkit"
 	x3uiapi "github.com/eterline/x3ui-exporter/pkg/x3-ui-api"
+	xraystats "github.com/eterline/x3ui-exporter/pkg/xray-stats"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -27,32 +42,300 @@ func Execute(cfg config.Configuration, root *toolkit.AppStarter) {
 	log.Info("service started")
 	defer log.Info("service stopped")
 
-	api, err := x3uiapi.NewClient(cfg.DashboardURL, cfg.DashboardBase, cfg.DashboardLogin, cfg.DashboardPassword, "")
+	ident, err := identity.NewResolver(cfg.IdentityMode, cfg.IdentitySalt, cfg.IdentityAliases)
 	if err != nil {
-		log.Fatalf("failed to init 3x-ui api: %v", err)
+		log.Fatalf("failed to init client identities: %v", err)
 	}
 
-	registry := metrics.NewMetricsReg(log)
-	stats := x3uiapi.NewStatsHandler()
+	rules, err := relabelRules(cfg.RelabelConfig)
+	if err != nil {
+		log.Fatalf("failed to load relabel rules: %v", err)
+	}
+
+	groups, err := group.NewGrouper(cfg.GroupBy, cfg.GroupSeparator, cfg.GroupMapping)
+	if err != nil {
+		log.Fatalf("failed to init client groups: %v", err)
+	}
+
+	registry := metrics.NewMetricsReg(log,
+		metrics.WithNamespace(cfg.Namespace),
+		metrics.WithLegacyNames(cfg.LegacyMetrics),
+		metrics.WithIdentity(ident),
+		metrics.WithRelabel(rules),
+		metrics.WithGroups(groups),
+		metrics.WithClientSeries(!cfg.NoClientSeries),
+		metrics.WithBuildInfo(cfg.BuildVersion, cfg.BuildCommit),
+		metrics.WithPushStaleness(cfg.PushStaleAfter, cfg.DropStalePush),
+		metrics.WithClientGeo(cfg.GeoIPClients),
+	)
+	trusted, err := x3uiapi.ParseTrusted(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("failed to parse trusted proxies: %v", err)
+	}
+	stats := x3uiapi.NewStatsHandler(trusted...)
 	defer stats.Close()
 
-	scr := scrape.NewScraperXUI(root.Context, api)
+	var source scrape.DataSource
+
+	switch cfg.Source {
+	case "api":
+		xui, err := x3uiapi.NewClient(cfg.DashboardURL, cfg.DashboardBase, cfg.DashboardLogin, cfg.DashboardPassword, "")
+		if err != nil {
+			log.Fatalf("failed to init 3x-ui api: %v", err)
+		}
+		xui.OnLogin(registry.Exporter.ObserveLogin)
+		source = xui
+
+	case "db":
+		xdb, err := x3uiapi.OpenDatabase(cfg.SourceDB, logger.InitStorageLogger())
+		if err != nil {
+			log.Fatalf("failed to open 3x-ui database: %v", err)
+		}
+		defer xdb.Close()
+		source = xdb
+
+	case "fixture":
+		fx, err := scrape.LoadFixture(cfg.SourceFixture)
+		if err != nil {
+			log.Fatalf("failed to load panel fixture: %v", err)
+		}
+		source = fx
+
+	case "xray":
+		addr := cfg.XrayAPI
+		if addr == "" {
+			addr, err = xraystats.AddressFromConfig(cfg.XrayConfig)
+			if err != nil {
+				log.Fatalf("failed to read xray api address: %v", err)
+			}
+		}
+
+		xc, err := xraystats.NewClient(addr)
+		if err != nil {
+			log.Fatalf("failed to init xray api: %v", err)
+		}
+		defer xc.Close()
+		source = xraystats.NewSource(xc)
+
+	default:
+		log.Fatalf("unknown panel data source: %s", cfg.Source)
+	}
+
+	scr := scrape.NewScraperXUI(root.Context, source)
+	store := state.NewStore(panelName(cfg.DashboardURL), panelURL(cfg.DashboardURL, cfg.DashboardBase), ident)
+
+	access := accesslog.NewStats(cfg.AccessLogTop, cfg.IPWindows)
+
+	sinks := append([]scrapeSink{store.Update}, accessSinks(cfg, access)...)
+	observers := []scrapeObserver{}
+	pushSinks := []pushSink{}
+	apiOpts := []api.APIOptionFunc{}
+
+	// publish - sends events to notifiers, events are dropped without ones
+	publish := func([]notify.Event) {}
+	var watcher *notify.Watcher
+
+	if ns := notifiers(cfg); len(ns) > 0 {
+		watcher = notify.NewWatcher(panelName(cfg.DashboardURL), cfg.QuotaThresholds, cfg.ExpiryWarn)
+		dispatcher := notify.NewDispatcher(ns...)
+
+		publish = func(events []notify.Event) {
+			if dropped := dispatcher.Publish(events...); dropped > 0 {
+				log.Warnf("notification queue is full, %d events dropped", dropped)
+			}
+		}
+
+		sinks = append(sinks, func(_ []scrape.InboundStat, stats []scrape.ClientStat) {
+			publish(watcher.Evaluate(stats, time.Now()))
+		})
+		observers = append(observers, func(err error) {
+			publish(watcher.ObserveScrape(err, time.Now()))
+		})
+
+		go dispatcher.Run(root.Context, func(err error) {
+			log.Errorf("notification failed: %v", err)
+		})
+	}
+
+	if cfg.AnomalyFactor > 0 || cfg.AnomalyAbsolute > 0 {
+		detector, err := anomaly.NewDetector(anomaly.Params{
+			Factor:   cfg.AnomalyFactor,
+			MinRate:  cfg.AnomalyMinRate,
+			Absolute: cfg.AnomalyAbsolute,
+			Window:   cfg.AnomalyWindow,
+		})
+		if err != nil {
+			log.Fatalf("failed to init anomaly detection: %v", err)
+		}
+
+		sinks = append(sinks, func(_ []scrape.InboundStat, stats []scrape.ClientStat) {
+			now := time.Now()
+			for _, st := range stats {
+				detector.ObserveTotal(anomaly.SourceScrape, st.Email, st.Up+st.Down, now)
+			}
+		})
+		pushSinks = append(pushSinks, func(u x3uiapi.TrafficUpdates) {
+			now := time.Now()
+			for _, cl := range u.Client {
+				detector.ObserveDelta(anomaly.SourcePush, cl.Email, cl.Up+cl.Down, now)
+			}
+		})
+
+		go processAnomalies(root.Context, detector, panelName(cfg.DashboardURL), registry, publish)
+	}
+
+	if cfg.HistoryDB != "" {
+		hist, err := history.Open(cfg.HistoryDB, history.Retention{
+			Hourly:  cfg.HistoryHourly,
+			Daily:   cfg.HistoryDaily,
+			Monthly: cfg.HistoryMonthly,
+		}, logger.InitStorageLogger())
+		if err != nil {
+			log.Fatalf("failed to init traffic history: %v", err)
+		}
+		defer hist.Close()
+
+		panel := panelName(cfg.DashboardURL)
+		sinks = append(sinks, func(_ []scrape.InboundStat, stats []scrape.ClientStat) {
+			if err := hist.Record(root.Context, panel, stats, time.Now()); err != nil {
+				log.Errorf("failed to record traffic history: %v", err)
+			}
+		})
+
+		// reported conditions survive restart, events are not repeated
+		if watcher != nil {
+			if err := watcher.Restore(root.Context, hist); err != nil {
+				log.Fatalf("failed to init notifications: %v", err)
+			}
+			sinks = append(sinks, func([]scrape.InboundStat, []scrape.ClientStat) {
+				if err := watcher.Sync(root.Context); err != nil {
+					log.Errorf("failed to save notify marks: %v", err)
+				}
+			})
+		}
 
-	go processUpdate(root.Context, stats, registry)
-	go processScrape(root.Context, scr, registry)
-	go startServer(root.Context, cfg, registry, stats)
+		go hist.RunCleanup(root.Context, func(err error) {
+			log.Errorf("traffic history cleanup failed: %v", err)
+		})
+
+		apiOpts = append(apiOpts, api.WithReports(hist, ident.Identity))
+	}
+
+	if cfg.AccessLog != "" {
+		var geo *geoip.Resolver
+
+		if cfg.GeoIPCountry != "" || cfg.GeoIPASN != "" {
+			geo, err = geoip.NewResolver(cfg.GeoIPCountry, cfg.GeoIPASN)
+			if err != nil {
+				log.Fatalf("failed to open geoip databases: %v", err)
+			}
+			defer geo.Close()
+
+			go geo.Watch(root.Context, func(err error) {
+				log.Errorf("geoip database reload failed: %v", err)
+			})
+		}
+
+		go processAccessLog(root.Context, accesslog.NewTailer(cfg.AccessLog), access, geo, registry)
+	}
+	if cfg.PanelIPPoll > 0 {
+		go processClientIPs(root.Context, scr, access, cfg.PanelIPPoll)
+	}
+	if accessEnabled(cfg) {
+		go processAccessStats(root.Context, access, registry)
+	}
+
+	if cfg.RemoteMode != "" {
+		instance := cfg.RemoteInstance
+		if instance == "" {
+			instance, _ = os.Hostname()
+		}
+
+		p, err := pusher.NewPusher(cfg.RemoteMode, cfg.RemoteURL, registry.Registry,
+			pusher.WithJob(cfg.RemoteJob, instance),
+			pusher.WithInterval(cfg.RemoteInterval),
+			pusher.WithRetries(cfg.RemoteRetries),
+			pusher.WithBasicAuth(cfg.RemoteUsername, cfg.RemotePassword),
+			pusher.WithBearerToken(cfg.RemoteBearer),
+			pusher.WithBuffer(cfg.RemoteBuffer, cfg.RemoteBufferSize),
+		)
+		if err != nil {
+			log.Fatalf("failed to init metrics push: %v", err)
+		}
+
+		go p.Run(root.Context, func(err error) {
+			log.Errorf("metrics push: %v", err)
+		})
+		log.Infof("metrics are pushed in %s mode to %s", cfg.RemoteMode, cfg.RemoteURL)
+	}
+
+	go processUpdate(root.Context, stats, registry, pushSinks...)
+	go processScrape(root.Context, scr, registry, observers, sinks...)
+	go startServer(root.Context, cfg.Listen, cfg.CrtFileSSL, cfg.KeyFileSSL, newRouter(registry, stats, api.NewAPI(store, apiOpts...)))
 
 	log.Infof("server listen in: %s", cfg.Listen)
 
+	if h := identityHandler(ident, cfg.IdentityToken); h != nil {
+		if err := identity.CheckListen(cfg.IdentityListen); err != nil {
+			log.Fatalf("failed to init identity lookup: %v", err)
+		}
+
+		// lookup reveals real emails, so it is never served on public metrics listener
+		lookup := chi.NewMux()
+		lookup.Get("/identity", h.ServeHTTP)
+		go startServer(root.Context, cfg.IdentityListen, "", "", lookup)
+
+		log.Infof("identity lookup listen in: %s", cfg.IdentityListen)
+	}
+
 	root.Wait()
 	root.WaitThreads(waitDuration)
 }
 
-func processUpdate(ctx context.Context, stats *x3uiapi.StatsHandle, reg *metrics.MetricsReg) {
+// panelName - panel name for API, host of dashboard url or local for panel database source
+func panelName(dashboard string) string {
+	if dashboard == "" {
+		return "local"
+	}
+
+	u, err := url.Parse(dashboard)
+	if err != nil || u.Host == "" {
+		return dashboard
+	}
+	return u.Host
+}
+
+// panelURL - dashboard url without credentials
+func panelURL(dashboard, base string) string {
+	u, err := url.Parse(dashboard)
+	if err != nil {
+		return ""
+	}
+
+	u.User = nil
+	if base != "" {
+		u = u.JoinPath(base)
+	}
+	return u.String()
+}
+
+func relabelRules(file string) (*relabel.Relabeler, error) {
+	if file == "" {
+		return relabel.New()
+	}
+	return relabel.LoadFile(file)
+}
+
+// pushSink - consumer of successful panel traffic push
+type pushSink func(u x3uiapi.TrafficUpdates)
+
+func processUpdate(ctx context.Context, stats *x3uiapi.StatsHandle, reg *metrics.MetricsReg, sinks ...pushSink) {
 	for u := range stats.Updates(ctx) {
 
 		log.Debug("got new traffic stats")
 
+		reg.ObservePush(u.Source, u.Err)
+
 		if u.Err != nil {
 			log.Errorf("failed update traffic stats: %v", u.Err)
 			continue
@@ -63,12 +346,33 @@ func processUpdate(ctx context.Context, stats *x3uiapi.StatsHandle, reg *metrics
 		}
 
 		for _, inb := range u.Updates.Inbound {
-			reg.UpdateInbound(inb)
+			updateTraffic(reg, inb)
+		}
+
+		for _, sink := range sinks {
+			sink(u.Updates)
 		}
+
+		reg.Publish()
+	}
+}
+
+// updateTraffic - splits panel traffic entries into inbound and outbound ones
+func updateTraffic(reg *metrics.MetricsReg, t x3uiapi.InboundTraffic) {
+	if t.IsOutbound {
+		reg.UpdateOutbound(t)
+		return
 	}
+	reg.UpdateInbound(t)
 }
 
-func processScrape(ctx context.Context, c *scrape.ScraperXUI, reg *metrics.MetricsReg) {
+// scrapeSink - consumer of successful panel scrape result
+type scrapeSink func(inbounds []scrape.InboundStat, stats []scrape.ClientStat)
+
+// scrapeObserver - consumer of every panel scrape outcome
+type scrapeObserver func(err error)
+
+func processScrape(ctx context.Context, c *scrape.ScraperXUI, reg *metrics.MetricsReg, observers []scrapeObserver, sinks ...scrapeSink) {
 	ticker := time.NewTicker(scrapeDuration)
 	defer ticker.Stop()
 
@@ -82,29 +386,85 @@ func processScrape(ctx context.Context, c *scrape.ScraperXUI, reg *metrics.Metri
 				log.Debug("scrape stats started")
 				defer log.Debug("stats scraped")
 
-				stats, err := scr.ScrapeInboundStats()
+				start := time.Now()
+
+				inbounds, stats, err := scr.ScrapeInbounds()
+				re.Exporter.ObserveScrape(start, err, scrape.ErrorReason(err))
+				for _, observe := range observers {
+					observe(err)
+				}
 				if err != nil {
 					log.Error(err)
 					return
 				}
 
+				exporters := make([]metrics.StatsExporter, 0, len(stats))
 				for _, stat := range stats {
-					re.UpdateStats(stat, stat)
+					exporters = append(exporters, stat)
+				}
+				re.UpdateStats(exporters)
+
+				traffic, err := scr.ScrapeTraffic()
+				switch {
+				case err == nil:
+					for _, t := range traffic {
+						updateTraffic(re, t)
+					}
+				case !errors.Is(err, x3uiapi.ErrNotSupported):
+					log.Error(err)
+				}
+
+				outbounds, err := scr.ScrapeOutbounds()
+				switch {
+				case err == nil:
+					for _, out := range outbounds {
+						re.UpdateOutboundStats(out)
+					}
+				case !errors.Is(err, x3uiapi.ErrNotSupported):
+					log.Error(err)
+				}
+
+				sys, err := scr.ScrapeSys()
+				switch {
+				case err == nil:
+					re.UpdateXraySys(sys)
+				case !errors.Is(err, x3uiapi.ErrNotSupported):
+					log.Error(err)
+				}
+
+				re.Publish()
+
+				for _, sink := range sinks {
+					sink(inbounds, stats)
 				}
 			}(c, reg)
 		}
 	}
 }
 
-func startServer(ctx context.Context, cfg config.Configuration, reg *metrics.MetricsReg, stats *x3uiapi.StatsHandle) {
+// identityHandler - reverse identity lookup, nil if endpoint is disabled
+func identityHandler(ident *identity.Resolver, token string) http.Handler {
+	if token == "" || ident.Mode() == identity.ModeRaw {
+		return nil
+	}
+	return ident.Handler(token)
+}
+
+func newRouter(reg *metrics.MetricsReg, stats *x3uiapi.StatsHandle, v1 *api.API) http.Handler {
 
 	r := chi.NewMux()
-	r.Get("/metric", reg.Metric().ServeHTTP)
-	r.Post("/metric", stats.ServeHTTP)
+	r.Get("/metric", reg.Exporter.InstrumentHandler("metric", reg.Metric()).ServeHTTP)
+	r.Post("/metric", reg.Exporter.InstrumentHandler("push", stats).ServeHTTP)
+	r.Mount("/api/v1", v1.Routes())
+
+	return r
+}
+
+func startServer(ctx context.Context, addr, cert, key string, r http.Handler) {
 
-	srv := server.NewMetricsServer(r, cfg.Listen)
+	srv := server.NewMetricsServer(r, addr)
 	go func() {
-		err := srv.Listen(cfg.CrtFileSSL, cfg.KeyFileSSL)
+		err := srv.Listen(cert, key)
 		switch {
 		case err == http.ErrServerClosed:
 			return
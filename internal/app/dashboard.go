@@ -0,0 +1,58 @@
+package app
+
+import (
+	"io"
+	"os"
+
+	"github.com/eterline/x3ui-exporter/internal/config"
+	"github.com/eterline/x3ui-exporter/internal/service/dashboard"
+	"github.com/eterline/x3ui-exporter/internal/service/group"
+	"github.com/eterline/x3ui-exporter/internal/service/metrics"
+	"github.com/eterline/x3ui-exporter/pkg/logger"
+)
+
+// Dashboard - writes Grafana dashboard for configured namespace and exits
+func Dashboard(cfg config.Configuration) {
+	log = logger.ReturnEntry()
+	cmd := cfg.GrafanaDashboard
+
+	board := dashboard.Generate(dashboard.Params{
+		Title:       cmd.Title,
+		Namespace:   cfg.Namespace,
+		Groups:      (cfg.GroupBy != "" && group.Mode(cfg.GroupBy) != group.ModeNone) || cfg.GroupMapping != "",
+		ClientLabel: clientLabel(cfg),
+	})
+
+	var out io.Writer = os.Stdout
+	if cmd.Output != "" {
+		f, err := os.Create(cmd.Output)
+		if err != nil {
+			log.Fatalf("failed to create dashboard file: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := board.Write(out); err != nil {
+		log.Fatalf("failed to write dashboard: %v", err)
+	}
+}
+
+// clientLabel - client label name after relabel rules, empty when client series are not exported
+func clientLabel(cfg config.Configuration) string {
+	if cfg.NoClientSeries {
+		return ""
+	}
+
+	rules, err := relabelRules(cfg.RelabelConfig)
+	if err != nil {
+		log.Fatalf("failed to load relabel rules: %v", err)
+	}
+
+	label, ok := rules.Target(metrics.ClientProbe(cfg.Namespace, "client@example.com"), metrics.ClientLabel)
+	if !ok {
+		log.Warn("relabel rules drop client label, per client panels are left out")
+		return ""
+	}
+	return label
+}
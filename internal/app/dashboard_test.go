@@ -0,0 +1,69 @@
+package app
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eterline/x3ui-exporter/internal/config"
+	"github.com/eterline/x3ui-exporter/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+func TestClientLabel(t *testing.T) {
+	discard := logrus.New()
+	discard.SetOutput(io.Discard)
+	log = logger.LogWorker{Entry: logrus.NewEntry(discard)}
+
+	rules := func(yaml string) string {
+		path := filepath.Join(t.TempDir(), "relabel.yml")
+		if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.Configuration
+		want string
+	}{
+		{
+			name: "default",
+			cfg:  config.Configuration{Namespace: "xui"},
+			want: "email",
+		},
+		{
+			name: "renamed",
+			cfg: config.Configuration{Namespace: "xui", RelabelConfig: rules(`relabel_configs:
+  - source_labels: [email]
+    target_label: client
+  - regex: email
+    action: labeldrop
+`)},
+			want: "client",
+		},
+		{
+			name: "dropped",
+			cfg: config.Configuration{Namespace: "xui", RelabelConfig: rules(`relabel_configs:
+  - regex: email
+    action: labeldrop
+`)},
+			want: "",
+		},
+		{
+			name: "no client series",
+			cfg:  config.Configuration{Namespace: "xui", NoClientSeries: true},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clientLabel(tt.cfg); got != tt.want {
+				t.Errorf("clientLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"github.com/eterline/x3ui-exporter/internal/config"
+	"github.com/eterline/x3ui-exporter/internal/service/notify"
+)
+
+// notifiers - configured event delivery channels
+func notifiers(cfg config.Configuration) []notify.Notifier {
+	ns := []notify.Notifier{}
+
+	for _, url := range cfg.WebhookURLs {
+		wh, err := notify.NewWebhook(url, cfg.WebhookTemplate, cfg.NotifyRetries)
+		if err != nil {
+			log.Fatalf("failed to init webhook: %v", err)
+		}
+		ns = append(ns, wh)
+	}
+
+	if cfg.TelegramToken != "" && len(cfg.TelegramChats) > 0 {
+		tg, err := notify.NewTelegram(cfg.TelegramAPI, cfg.TelegramToken, cfg.TelegramChats,
+			cfg.TelegramTemplate, cfg.TelegramInterval, cfg.NotifyRetries)
+		if err != nil {
+			log.Fatalf("failed to init telegram notifier: %v", err)
+		}
+		ns = append(ns, tg)
+	}
+
+	return ns
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/eterline/x3ui-exporter/internal/config"
+	"github.com/eterline/x3ui-exporter/internal/service/history"
+	"github.com/eterline/x3ui-exporter/internal/service/identity"
+	"github.com/eterline/x3ui-exporter/internal/service/report"
+	"github.com/eterline/x3ui-exporter/pkg/logger"
+	"github.com/eterline/x3ui-exporter/pkg/toolkit"
+)
+
+// Report - writes client usage report from traffic history and exits
+func Report(cfg config.Configuration, root *toolkit.AppStarter) {
+	log = logger.ReturnEntry()
+	cmd := cfg.Report
+
+	if cfg.HistoryDB == "" {
+		log.Fatal("report requires traffic history database, set --history-db")
+	}
+
+	// report shows the same client identities as exported metrics and API
+	ident, err := identity.NewResolver(cfg.IdentityMode, cfg.IdentitySalt, cfg.IdentityAliases)
+	if err != nil {
+		log.Fatalf("failed to init client identities: %v", err)
+	}
+
+	params := report.Params{
+		Panel:    cmd.Panel,
+		Inbound:  cmd.Inbound,
+		Email:    cmd.Email,
+		Identity: ident.Identity,
+	}
+	params.From, params.To = report.LastMonth(time.Now())
+
+	if cmd.From != "" {
+		if params.From, err = report.ParseDate(cmd.From); err != nil {
+			log.Fatalf("bad report range: %v", err)
+		}
+	}
+	if cmd.To != "" {
+		if params.To, err = report.ParseDate(cmd.To); err != nil {
+			log.Fatalf("bad report range: %v", err)
+		}
+	}
+
+	format := cmd.Format
+	if format == "" {
+		format = report.FormatCSV
+	}
+
+	hist, err := history.Open(cfg.HistoryDB, history.Retention{}, logger.InitStorageLogger())
+	if err != nil {
+		log.Fatalf("failed to open traffic history: %v", err)
+	}
+	defer hist.Close()
+
+	rep, err := report.Build(root.Context, hist, params)
+	if err != nil {
+		log.Fatalf("failed to build report: %v", err)
+	}
+
+	var out io.Writer = os.Stdout
+	if cmd.Output != "" {
+		f, err := os.Create(cmd.Output)
+		if err != nil {
+			log.Fatalf("failed to create report file: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := rep.Write(out, format); err != nil {
+		log.Fatalf("failed to write report: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"io"
+	"os"
+	"slices"
+
+	"github.com/eterline/x3ui-exporter/internal/config"
+	"github.com/eterline/x3ui-exporter/internal/service/rules"
+	"github.com/eterline/x3ui-exporter/pkg/logger"
+)
+
+// defaultQuotaShare - quota alert share if no quota thresholds are configured
+const defaultQuotaShare = 0.9
+
+// Rules - writes Prometheus rules for configured namespace and exits
+func Rules(cfg config.Configuration) {
+	log = logger.ReturnEntry()
+
+	// alert fires on the lowest configured quota event threshold
+	share := defaultQuotaShare
+	if len(cfg.QuotaThresholds) > 0 {
+		share = slices.Min(cfg.QuotaThresholds)
+	}
+
+	file := rules.Generate(rules.Params{
+		Namespace:  cfg.Namespace,
+		QuotaShare: share,
+		ExpiryWarn: cfg.ExpiryWarn,
+	})
+
+	var out io.Writer = os.Stdout
+	if cfg.Rules.Output != "" {
+		f, err := os.Create(cfg.Rules.Output)
+		if err != nil {
+			log.Fatalf("failed to create rules file: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := file.Write(out); err != nil {
+		log.Fatalf("failed to write rules: %v", err)
+	}
+}
@@ -1,13 +1,18 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/alexflint/go-arg"
 )
 
 type Configuration struct {
+	BuildVersion string `arg:"-"`
+	BuildCommit  string `arg:"-"`
+
 	LogDir    string `arg:"--log-dir,env:LOG_DIR" help:"log file directory"`
 	LogPretty bool   `arg:"--log-json,env:LOG_PRETTY" help:"log format in JSON syntax"`
 	Debug     bool   `arg:"--debug,env:ENV_DEBUG" help:"allow debug logging level"`
@@ -20,6 +25,107 @@ type Configuration struct {
 	DashboardBase     string `arg:"--base,env:BASE" help:"3X-UI dashboard url additional base"`
 	DashboardLogin    string `arg:"--login,env:LOGIN" help:"3X-UI user login"`
 	DashboardPassword string `arg:"--password,env:PASSWORD" help:"3X-UI user password"`
+
+	Source        string `arg:"--source,env:SOURCE" help:"panel data source: api, db, fixture or xray"`
+	SourceDB      string `arg:"--xui-db,env:XUI_DB" help:"3X-UI panel database file for db source"`
+	SourceFixture string `arg:"--fixture,env:FIXTURE" help:"recorded panel responses JSON file for fixture source"`
+	XrayAPI       string `arg:"--xray-api,env:XRAY_API" help:"Xray API address for xray source, read from Xray config if empty"`
+	XrayConfig    string `arg:"--xray-config,env:XRAY_CONFIG" help:"panel Xray config file with API inbound for xray source"`
+
+	Namespace     string `arg:"--namespace,env:NAMESPACE" help:"exported metrics namespace prefix"`
+	LegacyMetrics bool   `arg:"--legacy-metrics,env:LEGACY_METRICS" help:"also export old unprefixed metric names"`
+
+	IdentityMode    string `arg:"--identity-mode,env:IDENTITY_MODE" help:"client email label mode: raw, hash or alias"`
+	IdentitySalt    string `arg:"--identity-salt,env:IDENTITY_SALT" help:"salt for hashed client identities"`
+	IdentityAliases string `arg:"--identity-aliases,env:IDENTITY_ALIASES" help:"YAML file with email to alias mapping"`
+	IdentityToken   string `arg:"--identity-token,env:IDENTITY_TOKEN" help:"bearer token of /identity lookup endpoint, empty disables it"`
+	IdentityListen  string `arg:"--identity-listen,env:IDENTITY_LISTEN" help:"loopback address of /identity lookup listener"`
+
+	RelabelConfig string `arg:"--relabel-config,env:RELABEL_CONFIG" help:"YAML file with relabel rules for exported series"`
+
+	GroupBy        string `arg:"--group-by,env:GROUP_BY" help:"client group from email: none, prefix or suffix"`
+	GroupSeparator string `arg:"--group-separator,env:GROUP_SEPARATOR" help:"email separator for client group prefix or suffix"`
+	GroupMapping   string `arg:"--group-mapping,env:GROUP_MAPPING" help:"YAML file with email to client group mapping"`
+	NoClientSeries bool   `arg:"--no-client-series,env:NO_CLIENT_SERIES" help:"export only aggregated series, without per client ones"`
+
+	PushStaleAfter time.Duration `arg:"--push-stale-after,env:PUSH_STALE_AFTER" help:"mark push source stale after that time without pushes, 0 disables"`
+	DropStalePush  bool          `arg:"--drop-stale-push,env:DROP_STALE_PUSH" help:"stop exporting push derived series while pushes are stale"`
+	TrustedProxies []string      `arg:"--trusted-proxies,env:TRUSTED_PROXIES" help:"proxy addresses or CIDRs allowed to set push source by X-Real-IP header"`
+
+	HistoryDB      string        `arg:"--history-db,env:HISTORY_DB" help:"traffic history SQLite database file, empty disables history, also keeps reported notifications across restarts"`
+	HistoryHourly  time.Duration `arg:"--history-hourly-retention,env:HISTORY_HOURLY_RETENTION" help:"hourly traffic rollups retention, 0 keeps forever"`
+	HistoryDaily   time.Duration `arg:"--history-daily-retention,env:HISTORY_DAILY_RETENTION" help:"daily traffic rollups retention, 0 keeps forever"`
+	HistoryMonthly time.Duration `arg:"--history-monthly-retention,env:HISTORY_MONTHLY_RETENTION" help:"monthly traffic rollups retention, 0 keeps forever"`
+
+	AccessLog    string          `arg:"--access-log,env:ACCESS_LOG" help:"Xray access log file to tail, empty disables connection stats"`
+	AccessLogTop int             `arg:"--access-log-top,env:ACCESS_LOG_TOP" help:"exported top destinations count"`
+	IPWindows    []time.Duration `arg:"--ip-windows,env:IP_WINDOWS" help:"sliding windows of distinct client source IPs"`
+	PanelIPPoll  time.Duration   `arg:"--panel-ip-poll,env:PANEL_IP_POLL" help:"interval of polling client IPs recorded by panel for clients with IP limit, 0 disables"`
+
+	GeoIPCountry string `arg:"--geoip-country,env:GEOIP_COUNTRY" help:"MaxMind format country or city database for access log source addresses"`
+	GeoIPASN     string `arg:"--geoip-asn,env:GEOIP_ASN" help:"MaxMind format ASN database for access log source addresses"`
+	GeoIPClients bool   `arg:"--geoip-client-series,env:GEOIP_CLIENT_SERIES" help:"split country and ASN connections per client, series grow with clients times their networks"`
+
+	WebhookURLs     []string      `arg:"--webhook-url,env:WEBHOOK_URL" help:"webhook urls of client quota and expiry events"`
+	WebhookTemplate string        `arg:"--webhook-template,env:WEBHOOK_TEMPLATE" help:"Go template file of webhook JSON body, event JSON if empty"`
+	NotifyRetries   int           `arg:"--notify-retries,env:NOTIFY_RETRIES" help:"event delivery retries"`
+	QuotaThresholds []float64     `arg:"--quota-thresholds,env:QUOTA_THRESHOLDS" help:"client quota usage shares firing events"`
+	ExpiryWarn      time.Duration `arg:"--expiry-warn,env:EXPIRY_WARN" help:"fire event that long before client expiry, 0 disables"`
+
+	TelegramToken    string        `arg:"--telegram-token,env:TELEGRAM_TOKEN" help:"Telegram bot token for events"`
+	TelegramChats    []string      `arg:"--telegram-chat,env:TELEGRAM_CHAT" help:"Telegram chat ids receiving events"`
+	TelegramAPI      string        `arg:"--telegram-api,env:TELEGRAM_API" help:"Telegram Bot API base url"`
+	TelegramTemplate string        `arg:"--telegram-template,env:TELEGRAM_TEMPLATE" help:"Go template file of Telegram message text"`
+	TelegramInterval time.Duration `arg:"--telegram-interval,env:TELEGRAM_INTERVAL" help:"minimal interval between Telegram messages"`
+
+	AnomalyFactor   float64       `arg:"--anomaly-factor,env:ANOMALY_FACTOR" help:"client traffic rate multiple of its baseline that is anomaly, 0 disables"`
+	AnomalyMinRate  float64       `arg:"--anomaly-min-rate,env:ANOMALY_MIN_RATE" help:"bytes/s rate below which client traffic is never anomaly by baseline multiple"`
+	AnomalyAbsolute float64       `arg:"--anomaly-absolute,env:ANOMALY_ABSOLUTE" help:"client traffic bytes/s rate that is always anomaly, 0 disables"`
+	AnomalyWindow   time.Duration `arg:"--anomaly-window,env:ANOMALY_WINDOW" help:"client traffic rate baseline averaging window, must be positive"`
+
+	RemoteMode       string        `arg:"--remote-mode,env:REMOTE_MODE" help:"push metrics instead of being scraped: pushgateway or remote_write, empty disables"`
+	RemoteURL        string        `arg:"--remote-url,env:REMOTE_URL" help:"Pushgateway base url or remote write endpoint url"`
+	RemoteInterval   time.Duration `arg:"--remote-interval,env:REMOTE_INTERVAL" help:"metrics push interval"`
+	RemoteJob        string        `arg:"--remote-job,env:REMOTE_JOB" help:"job label of pushed metrics"`
+	RemoteInstance   string        `arg:"--remote-instance,env:REMOTE_INSTANCE" help:"instance label of pushed metrics, host name if empty"`
+	RemoteUsername   string        `arg:"--remote-username,env:REMOTE_USERNAME" help:"metrics push basic auth user"`
+	RemotePassword   string        `arg:"--remote-password,env:REMOTE_PASSWORD" help:"metrics push basic auth password"`
+	RemoteBearer     string        `arg:"--remote-bearer,env:REMOTE_BEARER" help:"metrics push bearer token, used instead of basic auth"`
+	RemoteRetries    int           `arg:"--remote-retries,env:REMOTE_RETRIES" help:"failed metrics push retries"`
+	RemoteBuffer     string        `arg:"--remote-buffer,env:REMOTE_BUFFER" help:"remote write buffer directory keeping requests during outages, empty disables"`
+	RemoteBufferSize int64         `arg:"--remote-buffer-size,env:REMOTE_BUFFER_SIZE" help:"remote write buffer size limit in bytes"`
+
+	Report *ReportCommand `arg:"subcommand:report" help:"print client usage report from traffic history"`
+	Rules  *RulesCommand  `arg:"subcommand:rules" help:"print Prometheus alerting and recording rules for exported metrics"`
+
+	GrafanaDashboard *DashboardCommand `arg:"subcommand:dashboard" help:"print Grafana dashboard JSON for exported metrics"`
+}
+
+// ReportCommand - usage report subcommand, range defaults to previous month
+type ReportCommand struct {
+	From    string `arg:"--from" help:"range start UTC date, YYYY-MM-DD"`
+	To      string `arg:"--to" help:"range end UTC date exclusive, YYYY-MM-DD"`
+	Panel   string `arg:"--panel" help:"only clients of panel"`
+	Inbound string `arg:"--inbound" help:"only clients of inbound"`
+	Email   string `arg:"--email" help:"only client with email"`
+	Format  string `arg:"--format" help:"report format: csv (default) or json"`
+	Output  string `arg:"--output,-o" help:"report file, stdout if empty"`
+}
+
+// RulesCommand - Prometheus rules subcommand, thresholds follow exporter notification settings
+type RulesCommand struct {
+	Output string `arg:"--output,-o" help:"rules file, stdout if empty"`
+}
+
+// DashboardCommand - Grafana dashboard subcommand, queries follow exporter namespace and grouping
+type DashboardCommand struct {
+	Title  string `arg:"--title" help:"dashboard title"`
+	Output string `arg:"--output,-o" help:"dashboard JSON file, stdout if empty"`
+}
+
+// Version - version string for go-arg --version flag
+func (c Configuration) Version() string {
+	return fmt.Sprintf("%s %s (%s)", selfExec(), c.BuildVersion, c.BuildCommit)
 }
 
 var (
@@ -42,6 +148,11 @@ func ParseArgs(c *Configuration) error {
 		p.WriteHelp(os.Stdout)
 		os.Exit(1)
 	}
+
+	if err == arg.ErrVersion {
+		fmt.Println(c.Version())
+		os.Exit(0)
+	}
 	return err
 }
 
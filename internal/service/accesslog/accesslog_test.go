@@ -0,0 +1,258 @@
+package accesslog
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func fixtureLines(t *testing.T) []string {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", "access.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	lines := []string{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func TestParseFixture(t *testing.T) {
+	local := func(s string) time.Time {
+		ts, _ := time.ParseInLocation(timeLayout, s, time.Local)
+		return ts
+	}
+
+	want := []struct {
+		line  int
+		entry Entry
+	}{
+		{0, Entry{
+			Time: local("2025/01/02 15:04:05"), Source: "1.2.3.4", Accepted: true,
+			Network: "tcp", Destination: "example.com", Port: "443",
+			Inbound: "vless-in", Outbound: "direct", Email: "alice@vpn.example",
+		}},
+		{1, Entry{
+			Time: local("2025/01/02 15:04:06"), Source: "1.2.3.4", Accepted: true,
+			Network: "udp", Destination: "8.8.8.8", Port: "53",
+			Inbound: "vless-in", Outbound: "dns-out", Email: "alice@vpn.example",
+		}},
+		{2, Entry{
+			Time: local("2025/01/02 15:04:07"), Source: "5.6.7.8", Accepted: true,
+			Network: "tcp", Destination: "example.com", Port: "443",
+			Inbound: "vmess-in", Outbound: "direct", Email: "bob@vpn.example",
+		}},
+		{3, Entry{
+			Time: local("2025/01/02 15:04:08"), Source: "2001:db8::1", Accepted: true,
+			Network: "tcp", Destination: "2001:db8::53", Port: "443",
+			Inbound: "vmess-in", Outbound: "direct", Email: "bob@vpn.example",
+		}},
+		{4, Entry{
+			Time: local("2025/01/02 15:04:09"), Source: "9.9.9.9",
+			Reason: "proxy/vless/encoding: invalid request user id",
+		}},
+		{5, Entry{
+			Time: local("2025/01/02 15:04:10"), Source: "1.2.3.4", Accepted: true,
+			Network: "tcp", Destination: "cdn.example.net", Port: "443",
+		}},
+	}
+
+	lines := fixtureLines(t)
+	for _, w := range want {
+		got, ok := Parse(lines[w.line])
+		if !ok {
+			t.Errorf("Parse(%q) rejected line", lines[w.line])
+			continue
+		}
+		if !reflect.DeepEqual(got, w.entry) {
+			t.Errorf("Parse(%q) = %+v, want %+v", lines[w.line], got, w.entry)
+		}
+	}
+
+	for _, i := range []int{6, 7} {
+		if _, ok := Parse(lines[i]); ok {
+			t.Errorf("Parse(%q) accepted line of other kind", lines[i])
+		}
+	}
+}
+
+func TestStatsFixture(t *testing.T) {
+	now := time.Date(2025, 1, 2, 16, 0, 0, 0, time.UTC)
+	s := NewStats(2, []time.Duration{time.Hour, 5 * time.Minute})
+
+	if !slices.Equal(s.Windows(), []time.Duration{5 * time.Minute, time.Hour}) {
+		t.Errorf("Windows() = %v, want ascending order", s.Windows())
+	}
+
+	for _, line := range fixtureLines(t) {
+		if e, ok := Parse(line); ok {
+			s.Observe(e, now)
+		}
+	}
+
+	// panel recorded IP seen half an hour ago
+	s.ObserveIP("alice@vpn.example", "7.7.7.7", now.Add(-30*time.Minute))
+	s.SetLimits(map[string]int{"alice@vpn.example": 1, "dave@vpn.example": 3})
+
+	got := map[string]ClientIPs{}
+	for _, cl := range s.SourceIPs(now) {
+		got[cl.Email] = cl
+	}
+
+	want := map[string]ClientIPs{
+		"alice@vpn.example": {Email: "alice@vpn.example", Counts: []int{1, 2}, Limit: 1},
+		"bob@vpn.example":   {Email: "bob@vpn.example", Counts: []int{2, 2}},
+		"carol@vpn.example": {Email: "carol@vpn.example", Counts: []int{1, 1}},
+		"dave@vpn.example":  {Email: "dave@vpn.example", Counts: []int{0, 0}, Limit: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SourceIPs() = %+v, want %+v", got, want)
+	}
+
+	top := s.TopDestinations()
+	wantTop := []Destination{{Host: "example.com", Count: 3}, {Host: "2001:db8::53", Count: 1}}
+	if !reflect.DeepEqual(top, wantTop) {
+		t.Errorf("TopDestinations() = %+v, want %+v", top, wantTop)
+	}
+
+	// IPs older than the largest window are forgotten
+	got = map[string]ClientIPs{}
+	for _, cl := range s.SourceIPs(now.Add(2 * time.Hour)) {
+		got[cl.Email] = cl
+	}
+	if len(got) != 2 || got["alice@vpn.example"].Counts[1] != 0 || got["dave@vpn.example"].Limit != 3 {
+		t.Errorf("SourceIPs() after windows passed = %+v, want only limited clients", got)
+	}
+}
+
+func TestTopNBounded(t *testing.T) {
+	top := newTopN(2)
+
+	for i := 0; i < 100; i++ {
+		top.add("hot.example")
+	}
+	for i := 0; i < 1000; i++ {
+		top.add(strings.Repeat("x", i%50+1) + ".example")
+	}
+
+	if len(top.counts) > 2*topCapacity {
+		t.Errorf("topN tracks %d keys, want at most %d", len(top.counts), 2*topCapacity)
+	}
+	if got := top.top(); len(got) != 2 || got[0].Host != "hot.example" {
+		t.Errorf("top() = %+v, want hot.example first", got)
+	}
+}
+
+// collector - lines received by tailer
+type collector struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (c *collector) add(line string) {
+	c.mu.Lock()
+	c.lines = append(c.lines, line)
+	c.mu.Unlock()
+}
+
+func (c *collector) wait(t *testing.T, n int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		if len(c.lines) >= n {
+			lines := slices.Clone(c.lines)
+			c.mu.Unlock()
+			return lines
+		}
+		c.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t.Fatalf("got lines %q, want %d", c.lines, n)
+	return nil
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTailerRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	appendFile(t, path, "old line written before start\n")
+
+	tl := NewTailer(path)
+	tl.poll = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	c := &collector{}
+
+	go func() {
+		defer close(done)
+		tl.Run(ctx, c.add, func(err error) { t.Errorf("tailer error: %v", err) })
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// wait for tailer to open file at its end
+	time.Sleep(50 * time.Millisecond)
+
+	appendFile(t, path, "first\nsec")
+	c.wait(t, 1)
+	appendFile(t, path, "ond\n")
+	c.wait(t, 2)
+
+	// rename rotation, rest of old file is read before switch
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path+".1", "third\n")
+	appendFile(t, path, "fourth\n")
+	c.wait(t, 4)
+
+	// truncate rotation restarts from file start
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "fifth\n")
+
+	got := c.wait(t, 5)
+	want := []string{"first", "second", "third", "fourth", "fifth"}
+	if !slices.Equal(got, want) {
+		t.Errorf("tailed lines = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+package accesslog
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Xray access log line, both old and new formats:
+//
+//	2025/01/02 15:04:05 1.2.3.4:5555 accepted tcp:example.com:443 [vless-in -> direct] email: user@mail
+//	2025/01/02 15:04:05.123456 from tcp:1.2.3.4:5555 accepted udp:8.8.8.8:53 [vless-in >> dns-out] email: user@mail
+//	2025/01/02 15:04:05 1.2.3.4:5555 rejected  proxy/vless/encoding: invalid request user id
+var (
+	lineRe     = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?) (?:from )?(?:(?:tcp|udp):)?(\S+) (accepted|rejected)\s+(.*)$`)
+	acceptedRe = regexp.MustCompile(`^(?:(tcp|udp):)?(\S+)(?: \[(\S+) (?:->|>>) (\S+)\])?(?: email: (\S+))?`)
+)
+
+const timeLayout = "2006/01/02 15:04:05"
+
+// Entry - parsed access log line
+type Entry struct {
+	Time     time.Time
+	Source   string
+	Accepted bool
+
+	Network     string
+	Destination string
+	Port        string
+	Inbound     string
+	Outbound    string
+	Email       string
+
+	// Reason - rejection reason
+	Reason string
+}
+
+// Parse - parses access log line, false for lines of other kinds
+func Parse(line string) (Entry, bool) {
+	m := lineRe.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return Entry{}, false
+	}
+
+	e := Entry{
+		Source:   hostOf(m[2]),
+		Accepted: m[3] == "accepted",
+	}
+
+	ts := m[1]
+	if i := strings.IndexByte(ts, '.'); i > 0 {
+		ts = ts[:i]
+	}
+	e.Time, _ = time.ParseInLocation(timeLayout, ts, time.Local)
+
+	if !e.Accepted {
+		e.Reason = strings.TrimSpace(m[4])
+		return e, true
+	}
+
+	a := acceptedRe.FindStringSubmatch(m[4])
+	if a == nil {
+		return Entry{}, false
+	}
+
+	e.Network = a[1]
+	e.Destination, e.Port = splitHostPort(a[2])
+	e.Inbound = a[3]
+	e.Outbound = a[4]
+	e.Email = a[5]
+
+	return e, true
+}
+
+func hostOf(addr string) string {
+	host, _ := splitHostPort(addr)
+	return host
+}
+
+func splitHostPort(addr string) (host, port string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, ""
+	}
+	return host, port
+}
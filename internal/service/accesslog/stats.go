@@ -0,0 +1,212 @@
+package accesslog
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// topCapacity - tracked destinations per exported one, raises top-N accuracy
+const topCapacity = 10
+
+// Destination - connections count estimate of destination host
+type Destination struct {
+	Host  string
+	Count uint64
+}
+
+// topN - Space-Saving heavy hitters, keeps at most capacity counters.
+// New key replaces least counted one and inherits its count, so memory is bounded
+type topN struct {
+	size     int
+	capacity int
+	counts   map[string]uint64
+}
+
+func newTopN(size int) *topN {
+	return &topN{
+		size:     size,
+		capacity: size * topCapacity,
+		counts:   make(map[string]uint64, size*topCapacity),
+	}
+}
+
+func (t *topN) add(key string) {
+	if _, ok := t.counts[key]; ok || len(t.counts) < t.capacity {
+		t.counts[key]++
+		return
+	}
+
+	minKey, minCount := "", uint64(0)
+	for k, c := range t.counts {
+		if minKey == "" || c < minCount {
+			minKey, minCount = k, c
+		}
+	}
+
+	delete(t.counts, minKey)
+	t.counts[key] = minCount + 1
+}
+
+func (t *topN) top() []Destination {
+	top := make([]Destination, 0, len(t.counts))
+	for k, c := range t.counts {
+		top = append(top, Destination{Host: k, Count: c})
+	}
+
+	slices.SortFunc(top, func(a, b Destination) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Host, b.Host))
+	})
+
+	return top[:min(len(top), t.size)]
+}
+
+// ClientIPs - distinct source IPs of client per window and its configured limit
+type ClientIPs struct {
+	Email  string
+	Counts []int
+	Limit  int
+}
+
+// Stats - access log aggregates: distinct client source IPs in sliding windows and top destinations.
+// Client IPs recorded by panel are accounted too
+type Stats struct {
+	windows []time.Duration
+	keep    time.Duration
+
+	mu     sync.Mutex
+	ips    map[string]map[string]time.Time
+	limits map[string]int
+	dest   *topN
+}
+
+// NewStats - creates stats with top destinations size and distinct IPs windows
+func NewStats(top int, windows []time.Duration) *Stats {
+	windows = slices.Clone(windows)
+	slices.Sort(windows)
+
+	keep := time.Duration(0)
+	if len(windows) > 0 {
+		keep = windows[len(windows)-1]
+	}
+
+	return &Stats{
+		windows: windows,
+		keep:    keep,
+		ips:     map[string]map[string]time.Time{},
+		limits:  map[string]int{},
+		dest:    newTopN(top),
+	}
+}
+
+// Windows - distinct IPs windows in ascending order
+func (s *Stats) Windows() []time.Duration {
+	return s.windows
+}
+
+// Observe - accounts accepted connection
+func (s *Stats) Observe(e Entry, now time.Time) {
+	if !e.Accepted {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.Destination != "" {
+		s.dest.add(e.Destination)
+	}
+
+	s.observeIP(e.Email, e.Source, now)
+}
+
+// ObserveIP - marks client source IP seen now
+func (s *Stats) ObserveIP(email, ip string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.observeIP(email, ip, now)
+}
+
+func (s *Stats) observeIP(email, ip string, now time.Time) {
+	if email == "" || ip == "" {
+		return
+	}
+
+	seen, ok := s.ips[email]
+	if !ok {
+		seen = map[string]time.Time{}
+		s.ips[email] = seen
+	}
+
+	if now.After(seen[ip]) {
+		seen[ip] = now
+	}
+}
+
+// SetLimits - replaces configured distinct IPs limits by client email
+func (s *Stats) SetLimits(limits map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.limits = limits
+}
+
+// SourceIPs - distinct source IPs of every seen or limited client, counts are aligned with Windows.
+// IPs older than the largest window are dropped
+func (s *Stats) SourceIPs(now time.Time) []ClientIPs {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clients := make([]ClientIPs, 0, len(s.ips))
+
+	for email, seen := range s.ips {
+		cl := ClientIPs{
+			Email:  email,
+			Counts: make([]int, len(s.windows)),
+			Limit:  s.limits[email],
+		}
+
+		for ip, last := range seen {
+			age := now.Sub(last)
+			if age > s.keep {
+				delete(seen, ip)
+				continue
+			}
+
+			for i, w := range s.windows {
+				if age <= w {
+					cl.Counts[i]++
+				}
+			}
+		}
+
+		if len(seen) == 0 {
+			delete(s.ips, email)
+			continue
+		}
+		clients = append(clients, cl)
+	}
+
+	for email, limit := range s.limits {
+		if _, ok := s.ips[email]; ok {
+			continue
+		}
+		clients = append(clients, ClientIPs{
+			Email:  email,
+			Counts: make([]int, len(s.windows)),
+			Limit:  limit,
+		})
+	}
+
+	return clients
+}
+
+// TopDestinations - most connected destination hosts since start
+func (s *Stats) TopDestinations() []Destination {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dest.top()
+}
@@ -0,0 +1,181 @@
+package accesslog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	pollInterval = time.Second
+	maxLineSize  = 64 * 1024
+)
+
+// Tailer - follows log file like tail -F: starts at file end, survives
+// rotation by rename (file is reopened) and by truncate (read restarts from start)
+type Tailer struct {
+	path string
+	poll time.Duration
+
+	file   *os.File
+	reader *bufio.Reader
+	offset int64
+	// partial - line without newline yet, completed by next read
+	partial []byte
+	// overlong - current line exceeds max size and is skipped up to its end
+	overlong bool
+}
+
+func NewTailer(path string) *Tailer {
+	return &Tailer{
+		path: path,
+		poll: pollInterval,
+	}
+}
+
+// Run - calls fn for every new line until context is done
+func (t *Tailer) Run(ctx context.Context, fn func(line string), onErr func(error)) {
+	ticker := time.NewTicker(t.poll)
+	defer ticker.Stop()
+	defer t.close()
+
+	if err := t.open(true); err != nil && onErr != nil {
+		onErr(err)
+	}
+
+	for {
+		if t.file != nil {
+			if err := t.read(fn); err != nil && onErr != nil {
+				onErr(err)
+			}
+		}
+
+		if err := t.checkRotation(fn); err != nil && onErr != nil {
+			onErr(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *Tailer) open(atEnd bool) error {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+
+	offset := int64(0)
+	if atEnd {
+		if offset, err = f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	t.close()
+	t.file = f
+	t.reader = bufio.NewReaderSize(f, maxLineSize)
+	t.offset = offset
+	t.partial = nil
+	t.overlong = false
+
+	return nil
+}
+
+func (t *Tailer) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// read - reads all complete lines available now
+func (t *Tailer) read(fn func(line string)) error {
+	for {
+		chunk, err := t.reader.ReadSlice('\n')
+		t.offset += int64(len(chunk))
+
+		switch {
+		case err == nil:
+			if t.overlong {
+				t.overlong = false
+				continue
+			}
+
+			line := chunk
+			if len(t.partial) > 0 {
+				line = append(t.partial, chunk...)
+				t.partial = nil
+			}
+			fn(string(line[:len(line)-1]))
+
+		case errors.Is(err, bufio.ErrBufferFull):
+			t.overlong = true
+			t.partial = nil
+
+		case errors.Is(err, io.EOF):
+			if t.overlong {
+				return nil
+			}
+			if len(t.partial)+len(chunk) > maxLineSize {
+				t.overlong = true
+				t.partial = nil
+				return nil
+			}
+			t.partial = append(t.partial, chunk...)
+			return nil
+
+		default:
+			return err
+		}
+	}
+}
+
+// checkRotation - reopens rotated file and restarts truncated one
+func (t *Tailer) checkRotation(fn func(line string)) error {
+	st, err := os.Stat(t.path)
+	if err != nil {
+		// rotated file is not created yet
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if t.file == nil {
+		return t.open(false)
+	}
+
+	cur, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if !os.SameFile(st, cur) {
+		// rest of old file is written before rename
+		if err := t.read(fn); err != nil {
+			return err
+		}
+		return t.open(false)
+	}
+
+	if st.Size() < t.offset {
+		_, err := t.file.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		t.reader.Reset(t.file)
+		t.offset = 0
+		t.partial = nil
+		t.overlong = false
+	}
+
+	return nil
+}
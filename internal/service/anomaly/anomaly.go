@@ -0,0 +1,221 @@
+package anomaly
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// warmupSamples - rate samples before baseline is trusted for relative spikes
+	warmupSamples = 8
+
+	// staleAfter - stream without observations that long is forgotten
+	staleAfter = 15 * time.Minute
+)
+
+var (
+	ErrBadWindow = errors.New("anomaly baseline window must be positive")
+)
+
+// Stream sources, every source keeps own baseline of client rate
+const (
+	SourceScrape = "scrape"
+	SourcePush   = "push"
+)
+
+// Params - spike detection rules
+type Params struct {
+	// Factor - rate above baseline multiple is anomaly, 0 disables relative rule
+	Factor float64
+	// MinRate - bytes/s floor of relative rule, keeps idle clients from firing on small bursts
+	MinRate float64
+	// Absolute - bytes/s rate that is always anomaly, 0 disables absolute rule
+	Absolute float64
+	// Window - baseline exponential moving average time constant
+	Window time.Duration
+}
+
+// Score - client traffic rate against its baseline.
+// Value of 1 and above is anomaly
+type Score struct {
+	Email    string
+	Rate     float64
+	Baseline float64
+	Value    float64
+}
+
+// Anomaly - client which rate crossed into anomaly
+type Anomaly struct {
+	Email    string
+	Rate     float64
+	Baseline float64
+	Time     time.Time
+}
+
+type streamKey struct {
+	source string
+	email  string
+}
+
+type stream struct {
+	total    uint64
+	pending  uint64
+	last     time.Time
+	rate     float64
+	baseline float64
+	samples  int
+
+	// prev - baseline before latest rate was folded in, latest rate is compared to it
+	prev float64
+}
+
+// Detector - keeps rolling baseline of every client traffic rate and flags spikes.
+// Scrape totals and push deltas are separate streams, client score is the highest one
+type Detector struct {
+	params Params
+
+	mu      sync.Mutex
+	streams map[streamKey]*stream
+	firing  map[string]struct{}
+}
+
+// NewDetector - creates spike detector, baseline window must be positive
+func NewDetector(p Params) (*Detector, error) {
+	if p.Window <= 0 {
+		return nil, ErrBadWindow
+	}
+
+	return &Detector{
+		params:  p,
+		streams: map[streamKey]*stream{},
+		firing:  map[string]struct{}{},
+	}, nil
+}
+
+// ObserveTotal - accounts client accumulated traffic, counter reset restarts rate measure
+func (d *Detector) ObserveTotal(source, email string, total uint64, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.streams[streamKey{source, email}]
+	if !ok {
+		d.streams[streamKey{source, email}] = &stream{total: total, last: now}
+		return
+	}
+
+	if total < s.total {
+		s.total, s.last = total, now
+		return
+	}
+
+	delta := total - s.total
+	s.total = total
+	d.sample(s, delta, now)
+}
+
+// ObserveDelta - accounts client traffic increase, like panel push
+func (d *Detector) ObserveDelta(source, email string, delta uint64, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.streams[streamKey{source, email}]
+	if !ok {
+		// first push covers unknown interval, it only starts the measure
+		d.streams[streamKey{source, email}] = &stream{last: now}
+		return
+	}
+
+	d.sample(s, delta, now)
+}
+
+// sample - updates rate and baseline, increases of same instant are summed up to next one
+func (d *Detector) sample(s *stream, delta uint64, now time.Time) {
+	dt := now.Sub(s.last)
+	if dt <= 0 {
+		s.pending += delta
+		return
+	}
+
+	s.rate = float64(s.pending+delta) / dt.Seconds()
+	s.pending = 0
+	s.last = now
+
+	s.prev = s.baseline
+	if s.samples == 0 {
+		s.baseline = s.rate
+		s.prev = s.rate
+	} else {
+		alpha := 1 - math.Exp(-dt.Seconds()/d.params.Window.Seconds())
+		s.baseline += alpha * (s.rate - s.baseline)
+	}
+	s.samples++
+}
+
+// value - stream score, rate share of the lowest crossed limit
+func (d *Detector) value(s *stream) float64 {
+	score := 0.0
+
+	if d.params.Factor > 0 && s.samples > warmupSamples {
+		score = s.rate / max(d.params.Factor*s.prev, d.params.MinRate, 1)
+	}
+	if d.params.Absolute > 0 {
+		score = max(score, s.rate/d.params.Absolute)
+	}
+
+	return score
+}
+
+// Evaluate - returns client scores and anomalies started since previous call.
+// Anomaly fires once and fires again only after client rate went back to normal
+func (d *Detector) Evaluate(now time.Time) ([]Score, []Anomaly) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	clients := map[string]Score{}
+
+	for key, s := range d.streams {
+		if now.Sub(s.last) > staleAfter {
+			delete(d.streams, key)
+			continue
+		}
+
+		v := d.value(s)
+		if cur, ok := clients[key.email]; ok && cur.Value >= v {
+			continue
+		}
+		clients[key.email] = Score{
+			Email:    key.email,
+			Rate:     s.rate,
+			Baseline: s.prev,
+			Value:    v,
+		}
+	}
+
+	scores := make([]Score, 0, len(clients))
+	anomalies := []Anomaly{}
+	firing := map[string]struct{}{}
+
+	for email, sc := range clients {
+		scores = append(scores, sc)
+
+		if sc.Value < 1 {
+			continue
+		}
+		firing[email] = struct{}{}
+
+		if _, ok := d.firing[email]; !ok {
+			anomalies = append(anomalies, Anomaly{
+				Email:    email,
+				Rate:     sc.Rate,
+				Baseline: sc.Baseline,
+				Time:     now,
+			})
+		}
+	}
+
+	d.firing = firing
+
+	return scores, anomalies
+}
@@ -0,0 +1,215 @@
+package anomaly
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNewDetectorWindow(t *testing.T) {
+	for _, w := range []time.Duration{0, -time.Hour} {
+		if _, err := NewDetector(Params{Factor: 3, Window: w}); !errors.Is(err, ErrBadWindow) {
+			t.Errorf("NewDetector() window %v error = %v, want %v", w, err, ErrBadWindow)
+		}
+	}
+
+	if _, err := NewDetector(Params{Factor: 3, Window: time.Hour}); err != nil {
+		t.Errorf("NewDetector() error = %v", err)
+	}
+}
+
+// flow - steady client traffic of one source
+type flow struct {
+	source string
+	email  string
+	rate   float64
+}
+
+// phase - ticks of flows with evaluation after every tick
+type phase struct {
+	// gap - idle time before phase
+	gap time.Duration
+	// reset - scrape counters start again from zero
+	reset bool
+	// ticks - observations, zero only evaluates
+	ticks int
+	flows []flow
+
+	// fired - anomalies started during phase
+	fired []string
+	// scores - clients scored on last evaluation, nil skips check
+	scores []string
+}
+
+func TestDetector(t *testing.T) {
+	const tick = 10 * time.Second
+
+	scrape := func(email string, rate float64) flow { return flow{SourceScrape, email, rate} }
+	push := func(email string, rate float64) flow { return flow{SourcePush, email, rate} }
+
+	// baseline of 12 ticks is past warmup
+	baseline := phase{ticks: 12, flows: []flow{scrape("a", 1000)}}
+
+	tests := []struct {
+		name   string
+		params Params
+		phases []phase
+	}{
+		{
+			name:   "no relative alert during warmup",
+			params: Params{Factor: 3, Window: 10 * time.Minute},
+			phases: []phase{
+				{ticks: 3, flows: []flow{scrape("a", 1000)}},
+				{ticks: 3, flows: []flow{scrape("a", 1e6)}},
+			},
+		},
+		{
+			name:   "factor",
+			params: Params{Factor: 3, Window: 10 * time.Minute},
+			phases: []phase{
+				baseline,
+				{ticks: 1, flows: []flow{scrape("a", 10000)}, fired: []string{"a"}},
+			},
+		},
+		{
+			name:   "factor below min rate",
+			params: Params{Factor: 3, MinRate: 50000, Window: 10 * time.Minute},
+			phases: []phase{
+				baseline,
+				{ticks: 1, flows: []flow{scrape("a", 10000)}},
+			},
+		},
+		{
+			name:   "absolute during warmup",
+			params: Params{Absolute: 5000, Window: 10 * time.Minute},
+			phases: []phase{
+				{ticks: 2, flows: []flow{scrape("a", 6000), scrape("b", 4000)}, fired: []string{"a"}},
+			},
+		},
+		{
+			name:   "fires once and re-arms after normal rate",
+			params: Params{Factor: 3, Window: 10 * time.Minute},
+			phases: []phase{
+				baseline,
+				{ticks: 3, flows: []flow{scrape("a", 10000)}, fired: []string{"a"}},
+				{ticks: 3, flows: []flow{scrape("a", 1000)}},
+				{ticks: 1, flows: []flow{scrape("a", 20000)}, fired: []string{"a"}},
+			},
+		},
+		{
+			name:   "counter reset",
+			params: Params{Factor: 3, Window: 10 * time.Minute},
+			phases: []phase{
+				baseline,
+				{reset: true, ticks: 1, flows: []flow{scrape("a", 1000)}},
+				{ticks: 2, flows: []flow{scrape("a", 1000)}},
+			},
+		},
+		{
+			name:   "stale streams expire",
+			params: Params{Absolute: 5000, Window: 10 * time.Minute},
+			phases: []phase{
+				{ticks: 2, flows: []flow{scrape("a", 1000), push("b", 1000)}, scores: []string{"a", "b"}},
+				{gap: staleAfter, scores: []string{"a", "b"}},
+				{gap: time.Second, scores: []string{}},
+			},
+		},
+		{
+			name:   "scrape and push of same client",
+			params: Params{Factor: 3, Window: 10 * time.Minute},
+			phases: []phase{
+				{ticks: 12, flows: []flow{scrape("a", 1000), push("a", 1000)}, scores: []string{"a"}},
+				{ticks: 2, flows: []flow{scrape("a", 1000), push("a", 10000)}, fired: []string{"a"}, scores: []string{"a"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDetector(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			totals := map[streamKey]uint64{}
+
+			for i, p := range tt.phases {
+				now = now.Add(p.gap)
+				if p.reset {
+					clear(totals)
+				}
+
+				fired := []string{}
+				var scores []Score
+				evaluate := func() {
+					var anomalies []Anomaly
+					scores, anomalies = d.Evaluate(now)
+					for _, a := range anomalies {
+						fired = append(fired, a.Email)
+					}
+				}
+
+				if p.ticks == 0 {
+					evaluate()
+				}
+				for range p.ticks {
+					now = now.Add(tick)
+					for _, f := range p.flows {
+						inc := uint64(f.rate * tick.Seconds())
+						if f.source == SourcePush {
+							d.ObserveDelta(f.source, f.email, inc, now)
+							continue
+						}
+
+						key := streamKey{f.source, f.email}
+						totals[key] += inc
+						d.ObserveTotal(f.source, f.email, totals[key], now)
+					}
+					evaluate()
+				}
+
+				slices.Sort(fired)
+				if !slices.Equal(fired, p.fired) {
+					t.Errorf("phase %d fired = %v, want %v", i, fired, p.fired)
+				}
+
+				if p.scores == nil {
+					continue
+				}
+				emails := []string{}
+				for _, sc := range scores {
+					emails = append(emails, sc.Email)
+				}
+				slices.Sort(emails)
+				if !slices.Equal(emails, p.scores) {
+					t.Errorf("phase %d scores = %v, want %v", i, emails, p.scores)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectorClientScore(t *testing.T) {
+	d, err := NewDetector(Params{Absolute: 5000, Window: 10 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	d.ObserveTotal(SourceScrape, "a", 0, now)
+	d.ObserveDelta(SourcePush, "a", 0, now)
+
+	now = now.Add(10 * time.Second)
+	d.ObserveTotal(SourceScrape, "a", 10000, now)
+	d.ObserveDelta(SourcePush, "a", 60000, now)
+
+	scores, _ := d.Evaluate(now)
+	if len(scores) != 1 {
+		t.Fatalf("Evaluate() scores = %+v, want one client", scores)
+	}
+	if got := scores[0]; got.Rate != 6000 || got.Value != 1.2 {
+		t.Errorf("Evaluate() score = %+v, want push stream rate 6000 value 1.2", got)
+	}
+}
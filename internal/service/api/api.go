@@ -0,0 +1,311 @@
+package api
+
+import (
+	"cmp"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eterline/x3ui-exporter/internal/service/report"
+	"github.com/eterline/x3ui-exporter/internal/service/state"
+	"github.com/go-chi/chi/v5"
+)
+
+// Read-only JSON API over exporter traffic state
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+var (
+	ErrBadLimit  = errors.New("limit must be positive number")
+	ErrBadOffset = errors.New("offset must be non negative number")
+	ErrBadSort   = errors.New("unknown sort field")
+	ErrBadOrder  = errors.New("order must be asc or desc")
+	ErrNotFound  = errors.New("not found")
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+type StateProvider interface {
+	Snapshot() *state.Snapshot
+}
+
+type API struct {
+	state    StateProvider
+	reports  report.RecordSource
+	identity func(email string) string
+}
+
+type APIOptionFunc func(*API)
+
+// WithReports - enables usage reports over traffic history, emails are mapped by identity
+func WithReports(src report.RecordSource, identity func(email string) string) APIOptionFunc {
+	return func(a *API) {
+		a.reports = src
+		a.identity = identity
+	}
+}
+
+func NewAPI(s StateProvider, opts ...APIOptionFunc) *API {
+	a := &API{state: s}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Page - paginated list response
+type Page[T any] struct {
+	Items  []T `json:"items"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Routes - API router, mounted under /api/v1
+func (a *API) Routes() http.Handler {
+	r := chi.NewRouter()
+
+	r.Get("/openapi.json", a.openAPI)
+	r.Get("/panels", a.panels)
+	r.Get("/inbounds", a.inbounds)
+	r.Get("/clients", a.clients)
+	r.Get("/clients/{email}", a.client)
+
+	if a.reports != nil {
+		r.Get("/reports", a.report)
+	}
+
+	return r
+}
+
+func (a *API) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+func (a *API) panels(w http.ResponseWriter, r *http.Request) {
+	snap := a.state.Snapshot()
+	writeJSON(w, http.StatusOK, Page[state.Panel]{
+		Items:  snap.Panels,
+		Total:  len(snap.Panels),
+		Limit:  len(snap.Panels),
+		Offset: 0,
+	})
+}
+
+var inboundSorts = map[string]func(a, b state.Inbound) int{
+	"id":       func(a, b state.Inbound) int { return cmp.Compare(a.ID, b.ID) },
+	"name":     func(a, b state.Inbound) int { return strings.Compare(a.Name, b.Name) },
+	"tag":      func(a, b state.Inbound) int { return strings.Compare(a.Tag, b.Tag) },
+	"port":     func(a, b state.Inbound) int { return cmp.Compare(a.Port, b.Port) },
+	"up":       func(a, b state.Inbound) int { return cmp.Compare(a.Up, b.Up) },
+	"down":     func(a, b state.Inbound) int { return cmp.Compare(a.Down, b.Down) },
+	"total":    func(a, b state.Inbound) int { return cmp.Compare(a.Total, b.Total) },
+	"clients":  func(a, b state.Inbound) int { return cmp.Compare(a.Clients, b.Clients) },
+	"protocol": func(a, b state.Inbound) int { return strings.Compare(a.Protocol, b.Protocol) },
+}
+
+func (a *API) inbounds(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	items := filter(a.state.Snapshot().Inbounds, func(inb state.Inbound) bool {
+		return match(q.Get("panel"), inb.Panel) &&
+			match(q.Get("inbound"), inb.Name) &&
+			match(q.Get("protocol"), inb.Protocol)
+	})
+
+	page, err := paginate(r, items, inboundSorts, "id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+var clientSorts = map[string]func(a, b state.Client) int{
+	"email":   func(a, b state.Client) int { return strings.Compare(a.Email, b.Email) },
+	"inbound": func(a, b state.Client) int { return strings.Compare(a.Inbound, b.Inbound) },
+	"up":      func(a, b state.Client) int { return cmp.Compare(a.Up, b.Up) },
+	"down":    func(a, b state.Client) int { return cmp.Compare(a.Down, b.Down) },
+	"total":   func(a, b state.Client) int { return cmp.Compare(a.Total, b.Total) },
+	"quota":   func(a, b state.Client) int { return cmp.Compare(a.Quota, b.Quota) },
+	"expiry":  func(a, b state.Client) int { return cmp.Compare(a.Expiry, b.Expiry) },
+}
+
+func (a *API) clients(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	items := filter(a.state.Snapshot().Clients, func(cl state.Client) bool {
+		return match(q.Get("panel"), cl.Panel) &&
+			match(q.Get("inbound"), cl.Inbound) &&
+			match(q.Get("email"), cl.Email) &&
+			match(q.Get("protocol"), cl.Protocol)
+	})
+
+	page, err := paginate(r, items, clientSorts, "email")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (a *API) client(w http.ResponseWriter, r *http.Request) {
+	email := chi.URLParam(r, "email")
+
+	items := filter(a.state.Snapshot().Clients, func(cl state.Client) bool {
+		return cl.Email == email
+	})
+
+	if len(items) == 0 {
+		writeError(w, http.StatusNotFound, ErrNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (a *API) report(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	params := report.Params{
+		Panel:    q.Get("panel"),
+		Inbound:  q.Get("inbound"),
+		Email:    q.Get("email"),
+		Identity: a.identity,
+	}
+	params.From, params.To = report.LastMonth(time.Now())
+
+	var err error
+	if v := q.Get("from"); v != "" {
+		if params.From, err = report.ParseDate(v); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if params.To, err = report.ParseDate(v); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	format := q.Get("format")
+	switch format {
+	case "":
+		format = report.FormatJSON
+	case report.FormatJSON, report.FormatCSV:
+	default:
+		writeError(w, http.StatusBadRequest, report.ErrBadFormat)
+		return
+	}
+
+	rep, err := report.Build(r.Context(), a.reports, params)
+	switch {
+	case errors.Is(err, report.ErrBadRange):
+		writeError(w, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if format == report.FormatCSV {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="report_%s_%s.csv"`, rep.From, rep.To))
+		rep.WriteCSV(w)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// paginate - sorts and slices items by sort, order, limit and offset query parameters
+func paginate[T any](r *http.Request, items []T, sorts map[string]func(a, b T) int, defSort string) (Page[T], error) {
+	q := r.URL.Query()
+	page := Page[T]{Total: len(items), Limit: defaultLimit}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return page, ErrBadLimit
+		}
+		page.Limit = min(limit, maxLimit)
+	}
+
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return page, ErrBadOffset
+		}
+		page.Offset = offset
+	}
+
+	field := q.Get("sort")
+	if field == "" {
+		field = defSort
+	}
+
+	less, ok := sorts[field]
+	if !ok {
+		return page, ErrBadSort
+	}
+
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		asc := less
+		less = func(a, b T) int { return asc(b, a) }
+	default:
+		return page, ErrBadOrder
+	}
+
+	slices.SortStableFunc(items, less)
+
+	start := min(page.Offset, len(items))
+	end := min(start+page.Limit, len(items))
+	page.Items = items[start:end]
+
+	return page, nil
+}
+
+// filter - returns new slice, so snapshot items order is never changed
+func filter[T any](items []T, keep func(T) bool) []T {
+	out := make([]T, 0, len(items))
+	for _, item := range items {
+		if keep(item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func match(want, value string) bool {
+	return want == "" || want == value
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,381 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// gridWidth - Grafana dashboard grid columns
+	gridWidth = 24
+
+	// topClients - clients shown in top clients panels
+	topClients = 10
+
+	datasourceVar = "${datasource}"
+	instanceSel   = `instance=~"$instance"`
+)
+
+// Params - exporter settings dashboard queries must agree with
+type Params struct {
+	// Title - dashboard title
+	Title string
+	// Namespace - exported metrics namespace prefix
+	Namespace string
+	// Groups - client groups are exported, adds group traffic panel
+	Groups bool
+	// ClientLabel - exported client label name, empty leaves out per client panels
+	ClientLabel string
+}
+
+type (
+	// Dashboard - Grafana dashboard JSON model
+	Dashboard struct {
+		UID           string     `json:"uid"`
+		Title         string     `json:"title"`
+		Tags          []string   `json:"tags"`
+		Timezone      string     `json:"timezone"`
+		SchemaVersion int        `json:"schemaVersion"`
+		Refresh       string     `json:"refresh"`
+		Time          TimeRange  `json:"time"`
+		Templating    Templating `json:"templating"`
+		Panels        []Panel    `json:"panels"`
+	}
+
+	TimeRange struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+
+	Templating struct {
+		List []Variable `json:"list"`
+	}
+
+	Variable struct {
+		Name       string      `json:"name"`
+		Label      string      `json:"label"`
+		Type       string      `json:"type"`
+		Query      any         `json:"query"`
+		Datasource *Datasource `json:"datasource,omitempty"`
+		Refresh    int         `json:"refresh,omitempty"`
+		Multi      bool        `json:"multi,omitempty"`
+		IncludeAll bool        `json:"includeAll,omitempty"`
+		Current    any         `json:"current,omitempty"`
+	}
+
+	Datasource struct {
+		Type string `json:"type"`
+		UID  string `json:"uid"`
+	}
+
+	GridPos struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+		W int `json:"w"`
+		H int `json:"h"`
+	}
+
+	Target struct {
+		RefID        string `json:"refId"`
+		Expr         string `json:"expr"`
+		LegendFormat string `json:"legendFormat,omitempty"`
+		Instant      bool   `json:"instant,omitempty"`
+		Format       string `json:"format,omitempty"`
+	}
+
+	Panel struct {
+		ID              int            `json:"id"`
+		Type            string         `json:"type"`
+		Title           string         `json:"title"`
+		GridPos         GridPos        `json:"gridPos"`
+		Datasource      *Datasource    `json:"datasource,omitempty"`
+		Targets         []Target       `json:"targets,omitempty"`
+		FieldConfig     map[string]any `json:"fieldConfig,omitempty"`
+		Options         map[string]any `json:"options,omitempty"`
+		Transformations []any          `json:"transformations,omitempty"`
+	}
+)
+
+// layout - places panels on grid row by row
+type layout struct {
+	panels []Panel
+	x, y   int
+	height int
+}
+
+func (l *layout) row(title string) {
+	l.newline()
+	l.add(Panel{Type: "row", Title: title}, gridWidth, 1)
+	l.newline()
+}
+
+func (l *layout) add(p Panel, w, h int) {
+	if l.x+w > gridWidth {
+		l.newline()
+	}
+
+	p.ID = len(l.panels) + 1
+	p.GridPos = GridPos{X: l.x, Y: l.y, W: w, H: h}
+	if p.Type != "row" {
+		p.Datasource = &Datasource{Type: "prometheus", UID: datasourceVar}
+	}
+
+	l.panels = append(l.panels, p)
+	l.x += w
+	l.height = max(l.height, h)
+}
+
+func (l *layout) newline() {
+	if l.x == 0 {
+		return
+	}
+	l.y += l.height
+	l.x, l.height = 0, 0
+}
+
+// Generate - builds dashboard of exporter metrics
+func Generate(p Params) Dashboard {
+	m := func(name string) string {
+		return prometheus.BuildFQName(p.Namespace, "", name)
+	}
+	rate := func(name, by string) string {
+		return fmt.Sprintf(`sum by (%s) (rate(%s{%s}[$__rate_interval]))`, by, m(name), instanceSel)
+	}
+
+	client := p.ClientLabel
+	legend := "{{" + client + "}}"
+
+	l := &layout{}
+
+	l.row("Overview")
+	stats := []Panel{}
+	if client != "" {
+		stats = append(stats, stat("Clients", "none",
+			query(fmt.Sprintf(`count(%s{%s})`, m("client_used_bytes"), instanceSel), ""),
+		))
+	}
+	stats = append(stats,
+		stat("Traffic rate", "Bps",
+			query(fmt.Sprintf(`sum(rate(%s{%s}[$__rate_interval]))`, m("inbound_clients_traffic_bytes_total"), instanceSel), ""),
+		),
+		stat("Scrape success", "percentunit",
+			query(fmt.Sprintf(`sum(rate(%s{%s,result="success"}[$__rate_interval])) / sum(rate(%s{%s}[$__rate_interval]))`,
+				m("exporter_scrapes_total"), instanceSel, m("exporter_scrapes_total"), instanceSel), ""),
+		),
+		stat("Last push age", "s",
+			query(fmt.Sprintf(`min(%s{%s})`, m("push_age_seconds"), instanceSel), ""),
+		),
+	)
+	for _, panel := range stats {
+		l.add(panel, gridWidth/len(stats), 4)
+	}
+	l.add(timeseries("Traffic by direction", "Bps",
+		query(rate("inbound_clients_traffic_bytes_total", "direction"), "{{direction}}"),
+	), gridWidth, 8)
+
+	if client != "" {
+		l.row("Top clients")
+		l.add(timeseries(fmt.Sprintf("Top %d clients traffic", topClients), "Bps",
+			query(fmt.Sprintf(`topk(%d, %s)`, topClients, rate("inbound_client_traffic_bytes_total", client)), legend),
+		), 12, 9)
+		l.add(bargauge(fmt.Sprintf("Top %d clients usage", topClients), "bytes",
+			instant(fmt.Sprintf(`topk(%d, sum by (%s) (%s{%s}))`, topClients, client, m("client_used_bytes"), instanceSel), legend),
+		), 12, 9)
+	}
+
+	l.row("Inbounds")
+	l.add(timeseries("Inbound traffic", "Bps",
+		query(rate("inbound_clients_traffic_bytes_total", "inbound, direction"), "{{inbound}} {{direction}}"),
+	), 12, 8)
+	l.add(timeseries("Protocol traffic", "Bps",
+		query(rate("protocol_traffic_bytes_total", "protocol, direction"), "{{protocol}} {{direction}}"),
+	), 12, 8)
+	if p.Groups {
+		l.add(timeseries("Client group traffic", "Bps",
+			query(rate("group_traffic_bytes_total", "group"), "{{group}}"),
+		), gridWidth, 8)
+	}
+
+	if client != "" {
+		l.row("Quota and expiry")
+		l.add(quotaTable(m, client), gridWidth, 10)
+	}
+
+	l.row("Exporter health")
+	l.add(timeseries("Scrapes", "ops",
+		query(rate("exporter_scrapes_total", "result"), "{{result}}"),
+		query(rate("exporter_scrape_errors_total", "reason"), "error {{reason}}"),
+	), 8, 8)
+	l.add(timeseries("Scrape duration p95", "s",
+		query(fmt.Sprintf(`histogram_quantile(0.95, sum by (le) (rate(%s{%s}[$__rate_interval])))`,
+			m("exporter_scrape_duration_seconds_bucket"), instanceSel), "p95"),
+	), 8, 8)
+	l.add(timeseries("Pushes and logins", "ops",
+		query(rate("exporter_pushes_total", "source, result"), "push {{source}} {{result}}"),
+		query(rate("exporter_login_attempts_total", "result"), "login {{result}}"),
+	), 8, 8)
+
+	title := p.Title
+	if title == "" {
+		title = "3X-UI exporter"
+	}
+
+	uid := "3xui-exporter"
+	if p.Namespace != "" {
+		uid = p.Namespace + "-exporter"
+	}
+
+	return Dashboard{
+		UID:           uid,
+		Title:         title,
+		Tags:          []string{"3x-ui", "xray"},
+		Timezone:      "browser",
+		SchemaVersion: 39,
+		Refresh:       "30s",
+		Time:          TimeRange{From: "now-6h", To: "now"},
+		Templating: Templating{List: []Variable{
+			{
+				Name:  "datasource",
+				Label: "Data source",
+				Type:  "datasource",
+				Query: "prometheus",
+			},
+			{
+				Name:       "instance",
+				Label:      "Instance",
+				Type:       "query",
+				Query:      fmt.Sprintf("label_values(%s, instance)", m("exporter_build_info")),
+				Datasource: &Datasource{Type: "prometheus", UID: datasourceVar},
+				Refresh:    2,
+				Multi:      true,
+				IncludeAll: true,
+				Current:    map[string]any{"text": "All", "value": "$__all"},
+			},
+		}},
+		Panels: l.panels,
+	}
+}
+
+// quotaTable - client usage, quota, usage share and expiry merged by client
+func quotaTable(m func(string) string, client string) Panel {
+	used := fmt.Sprintf(`sum by (%s) (%s{%s})`, client, m("client_used_bytes"), instanceSel)
+	quota := fmt.Sprintf(`sum by (%s) (%s{%s})`, client, m("client_quota_bytes"), instanceSel)
+
+	targets := []Target{
+		instant(used, ""),
+		instant(quota, ""),
+		instant(fmt.Sprintf(`%s / (%s > 0)`, used, quota), ""),
+		instant(fmt.Sprintf(`max by (%s) (%s{%s}) * 1000`, client, m("client_expiry_timestamp_seconds"), instanceSel), ""),
+	}
+	for i := range withRefs(targets) {
+		targets[i].Format = "table"
+	}
+
+	column := func(refID, name, unit string) map[string]any {
+		return map[string]any{
+			"matcher": map[string]any{"id": "byName", "options": "Value #" + refID},
+			"properties": []any{
+				map[string]any{"id": "displayName", "value": name},
+				map[string]any{"id": "unit", "value": unit},
+			},
+		}
+	}
+
+	return Panel{
+		Type:    "table",
+		Title:   "Client quota and expiry",
+		Targets: targets,
+		FieldConfig: map[string]any{
+			"defaults": map[string]any{},
+			"overrides": []any{
+				column("A", "Used", "bytes"),
+				column("B", "Quota", "bytes"),
+				column("C", "Quota share", "percentunit"),
+				column("D", "Expiry", "dateTimeAsIso"),
+			},
+		},
+		Options: map[string]any{
+			"showHeader": true,
+			"sortBy":     []any{map[string]any{"displayName": "Quota share", "desc": true}},
+		},
+		Transformations: []any{
+			map[string]any{"id": "merge", "options": map[string]any{}},
+			map[string]any{"id": "organize", "options": map[string]any{
+				"excludeByName": map[string]any{"Time": true},
+			}},
+		},
+	}
+}
+
+func query(expr, legend string) Target {
+	return Target{Expr: expr, LegendFormat: legend}
+}
+
+func instant(expr, legend string) Target {
+	return Target{Expr: expr, LegendFormat: legend, Instant: true}
+}
+
+// withRefs - sets target reference ids A, B, C...
+func withRefs(targets []Target) []Target {
+	for i := range targets {
+		targets[i].RefID = string(rune('A' + i))
+	}
+	return targets
+}
+
+func unitConfig(unit string) map[string]any {
+	return map[string]any{
+		"defaults":  map[string]any{"unit": unit},
+		"overrides": []any{},
+	}
+}
+
+func stat(title, unit string, targets ...Target) Panel {
+	return Panel{
+		Type:        "stat",
+		Title:       title,
+		Targets:     withRefs(targets),
+		FieldConfig: unitConfig(unit),
+		Options: map[string]any{
+			"reduceOptions": map[string]any{"calcs": []string{"lastNotNull"}},
+			"colorMode":     "value",
+		},
+	}
+}
+
+func timeseries(title, unit string, targets ...Target) Panel {
+	return Panel{
+		Type:        "timeseries",
+		Title:       title,
+		Targets:     withRefs(targets),
+		FieldConfig: unitConfig(unit),
+		Options: map[string]any{
+			"legend":  map[string]any{"displayMode": "table", "placement": "right", "calcs": []string{"mean", "max"}},
+			"tooltip": map[string]any{"mode": "multi", "sort": "desc"},
+		},
+	}
+}
+
+func bargauge(title, unit string, targets ...Target) Panel {
+	return Panel{
+		Type:        "bargauge",
+		Title:       title,
+		Targets:     withRefs(targets),
+		FieldConfig: unitConfig(unit),
+		Options: map[string]any{
+			"orientation":   "horizontal",
+			"displayMode":   "gradient",
+			"reduceOptions": map[string]any{"calcs": []string{"lastNotNull"}},
+		},
+	}
+}
+
+// Write - writes dashboard as indented JSON, ready for Grafana import
+func (d Dashboard) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
@@ -0,0 +1,95 @@
+package dashboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// exprs - all panel queries of dashboard
+func exprs(d Dashboard) []string {
+	out := []string{}
+	for _, p := range d.Panels {
+		for _, t := range p.Targets {
+			out = append(out, t.Expr, t.LegendFormat)
+		}
+	}
+	return out
+}
+
+func titles(d Dashboard) map[string]bool {
+	out := map[string]bool{}
+	for _, p := range d.Panels {
+		out[p.Title] = true
+	}
+	return out
+}
+
+func TestGenerateClientLabel(t *testing.T) {
+	d := Generate(Params{Namespace: "xui", ClientLabel: "client"})
+
+	joined := strings.Join(exprs(d), "\n")
+	for _, want := range []string{
+		`sum by (client) (rate(xui_inbound_client_traffic_bytes_total{instance=~"$instance"}[$__rate_interval]))`,
+		`topk(10, sum by (client) (xui_client_used_bytes{instance=~"$instance"}))`,
+		`sum by (client) (xui_client_quota_bytes{instance=~"$instance"})`,
+		`max by (client) (xui_client_expiry_timestamp_seconds{instance=~"$instance"}) * 1000`,
+		`{{client}}`,
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("queries miss %q", want)
+		}
+	}
+	if strings.Contains(joined, "email") {
+		t.Errorf("queries still use email label:\n%s", joined)
+	}
+}
+
+func TestGenerateNoClientSeries(t *testing.T) {
+	d := Generate(Params{Namespace: "xui"})
+
+	got := titles(d)
+	for _, title := range []string{"Clients", "Top clients", "Top 10 clients traffic", "Top 10 clients usage", "Quota and expiry", "Client quota and expiry"} {
+		if got[title] {
+			t.Errorf("per client panel %q generated without client series", title)
+		}
+	}
+	for _, title := range []string{"Overview", "Traffic rate", "Inbound traffic", "Exporter health"} {
+		if !got[title] {
+			t.Errorf("panel %q missing", title)
+		}
+	}
+
+	for _, expr := range exprs(d) {
+		if strings.Contains(expr, "xui_client_") || strings.Contains(expr, "by (email)") {
+			t.Errorf("query on per client series: %s", expr)
+		}
+	}
+}
+
+func TestGenerateLayout(t *testing.T) {
+	for _, p := range []Params{{ClientLabel: "email"}, {}} {
+		d := Generate(p)
+
+		ids := map[int]bool{}
+		for _, panel := range d.Panels {
+			if ids[panel.ID] {
+				t.Errorf("duplicate panel id %d", panel.ID)
+			}
+			ids[panel.ID] = true
+
+			if panel.GridPos.X+panel.GridPos.W > gridWidth {
+				t.Errorf("panel %q exceeds grid: %+v", panel.Title, panel.GridPos)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := d.Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !json.Valid(buf.Bytes()) {
+			t.Error("dashboard is not valid JSON")
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package geoip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Offline GeoIP and ASN lookups from local MaxMind format databases
+
+// Unknown - country or ASN of address missing in database
+const Unknown = "unknown"
+
+// reloadDelay - quiet period after last file event before reload, updaters may touch files in several steps
+var reloadDelay = 2 * time.Second
+
+var (
+	ErrNoDatabase = errors.New("no geoip database configured")
+)
+
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// Location - address enrichment, empty fields if database is not configured
+type Location struct {
+	Country string
+	ASN     string
+	ASOrg   string
+}
+
+// database - reloadable mmdb file
+type database struct {
+	path   string
+	mu     sync.RWMutex
+	reader *maxminddb.Reader
+}
+
+func openDatabase(path string) (*database, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	db := &database{path: path}
+	if err := db.reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// reload - reads file again and swaps reader, old one is closed after running lookups.
+// Database is read into memory instead of mapped, so file rewrite never changes bytes under lookups
+func (db *database) reload() error {
+	data, err := os.ReadFile(db.path)
+	if err != nil {
+		return fmt.Errorf("failed to open geoip database %s: %w", db.path, err)
+	}
+
+	r, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return fmt.Errorf("failed to open geoip database %s: %w", db.path, err)
+	}
+
+	db.mu.Lock()
+	old := db.reader
+	db.reader = r
+	db.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+func (db *database) lookup(ip net.IP, v any) (bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	_, ok, err := db.reader.LookupNetwork(ip, v)
+	return ok, err
+}
+
+func (db *database) close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.reader.Close()
+}
+
+// Resolver - country and ASN lookups, both databases are optional
+type Resolver struct {
+	country *database
+	asn     *database
+}
+
+// NewResolver - opens country (GeoLite2 Country or City) and ASN databases, empty path skips database
+func NewResolver(countryPath, asnPath string) (*Resolver, error) {
+	if countryPath == "" && asnPath == "" {
+		return nil, ErrNoDatabase
+	}
+
+	country, err := openDatabase(countryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	asn, err := openDatabase(asnPath)
+	if err != nil {
+		if country != nil {
+			country.close()
+		}
+		return nil, err
+	}
+
+	return &Resolver{country: country, asn: asn}, nil
+}
+
+func (r *Resolver) databases() []*database {
+	dbs := []*database{}
+	for _, db := range []*database{r.country, r.asn} {
+		if db != nil {
+			dbs = append(dbs, db)
+		}
+	}
+	return dbs
+}
+
+// Lookup - location of address, lookups are never made over network
+func (r *Resolver) Lookup(addr string) Location {
+	loc := Location{}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return loc
+	}
+
+	if r.country != nil {
+		rec := countryRecord{}
+		loc.Country = Unknown
+		if ok, err := r.country.lookup(ip, &rec); err == nil && ok && rec.Country.ISOCode != "" {
+			loc.Country = rec.Country.ISOCode
+		}
+	}
+
+	if r.asn != nil {
+		rec := asnRecord{}
+		loc.ASN = Unknown
+		if ok, err := r.asn.lookup(ip, &rec); err == nil && ok && rec.Number != 0 {
+			loc.ASN = "AS" + strconv.FormatUint(uint64(rec.Number), 10)
+			loc.ASOrg = rec.Organization
+		}
+	}
+
+	return loc
+}
+
+// Watch - reloads database when its file is atomically replaced until context is done.
+// Only file creation or rename in watched directory is followed, in place writes may be partial
+func (r *Resolver) Watch(ctx context.Context, onErr func(error)) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		onErr(err)
+		return
+	}
+	defer w.Close()
+
+	// directories are watched, so atomic file replace by rename is noticed
+	files := map[string]*database{}
+	for _, db := range r.databases() {
+		path, err := filepath.Abs(db.path)
+		if err != nil {
+			onErr(err)
+			continue
+		}
+
+		files[path] = db
+		if err := w.Add(filepath.Dir(path)); err != nil {
+			onErr(err)
+		}
+	}
+
+	// pending - replaced databases waiting for quiet period
+	pending := map[*database]struct{}{}
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case err := <-w.Errors:
+			onErr(err)
+
+		case ev := <-w.Events:
+			if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Rename) {
+				continue
+			}
+
+			db, ok := files[filepath.Clean(ev.Name)]
+			if !ok {
+				continue
+			}
+
+			pending[db] = struct{}{}
+			timer.Reset(reloadDelay)
+
+		case <-timer.C:
+			for db := range pending {
+				if err := db.reload(); err != nil {
+					onErr(err)
+				}
+			}
+			clear(pending)
+		}
+	}
+}
+
+func (r *Resolver) Close() error {
+	var errs []error
+	for _, db := range r.databases() {
+		errs = append(errs, db.close())
+	}
+	return errors.Join(errs...)
+}
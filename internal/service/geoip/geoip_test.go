@@ -0,0 +1,308 @@
+package geoip
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// mmdb - minimal MaxMind DB writer for IPv4 test databases with 24 bit records
+
+type mmdbNode struct {
+	child [2]*mmdbNode
+	data  [2][]byte
+}
+
+// encodeMMDB - encodes value of data section, supports strings, uint16, uint32, maps and slices
+func encodeMMDB(v any) []byte {
+	var out bytes.Buffer
+
+	control := func(typ int, size int) {
+		ext := typ > 7
+		head := typ << 5
+		if ext {
+			head = 0
+		}
+		if size < 29 {
+			out.WriteByte(byte(head | size))
+		} else {
+			out.WriteByte(byte(head | 29))
+		}
+		if ext {
+			out.WriteByte(byte(typ - 7))
+		}
+		if size >= 29 {
+			out.WriteByte(byte(size - 29))
+		}
+	}
+
+	unsigned := func(typ int, n uint64, width int) {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, n)
+		b = bytes.TrimLeft(b[8-width:], "\x00")
+		control(typ, len(b))
+		out.Write(b)
+	}
+
+	switch v := v.(type) {
+	case string:
+		control(2, len(v))
+		out.WriteString(v)
+	case uint16:
+		unsigned(5, uint64(v), 2)
+	case uint32:
+		unsigned(6, uint64(v), 4)
+	case uint64:
+		unsigned(9, v, 8)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		control(7, len(v))
+		for _, k := range keys {
+			out.Write(encodeMMDB(k))
+			out.Write(encodeMMDB(v[k]))
+		}
+	case []any:
+		control(11, len(v))
+		for _, e := range v {
+			out.Write(encodeMMDB(e))
+		}
+	default:
+		panic("unsupported mmdb value")
+	}
+
+	return out.Bytes()
+}
+
+// writeMMDB - writes IPv4 database with given networks and records
+func writeMMDB(t *testing.T, path, dbType string, networks map[string]map[string]any) {
+	t.Helper()
+
+	root := &mmdbNode{}
+	for cidr, rec := range networks {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ip := ipnet.IP.To4()
+		bits, _ := ipnet.Mask.Size()
+		node := root
+		for i := 0; i < bits; i++ {
+			bit := ip[i/8] >> (7 - i%8) & 1
+			if i == bits-1 {
+				node.data[bit] = encodeMMDB(rec)
+				break
+			}
+			if node.child[bit] == nil {
+				node.child[bit] = &mmdbNode{}
+			}
+			node = node.child[bit]
+		}
+	}
+
+	// nodes are numbered breadth first, root is zero
+	nodes := []*mmdbNode{root}
+	for i := 0; i < len(nodes); i++ {
+		for _, c := range nodes[i].child {
+			if c != nil {
+				nodes = append(nodes, c)
+			}
+		}
+	}
+	index := map[*mmdbNode]int{}
+	for i, n := range nodes {
+		index[n] = i
+	}
+
+	var tree, data bytes.Buffer
+	record := func(n *mmdbNode, bit int) int {
+		switch {
+		case n.child[bit] != nil:
+			return index[n.child[bit]]
+		case n.data[bit] != nil:
+			ptr := len(nodes) + 16 + data.Len()
+			data.Write(n.data[bit])
+			return ptr
+		default:
+			return len(nodes)
+		}
+	}
+	for _, n := range nodes {
+		for bit := range 2 {
+			r := record(n, bit)
+			tree.Write([]byte{byte(r >> 16), byte(r >> 8), byte(r)})
+		}
+	}
+
+	var out bytes.Buffer
+	out.Write(tree.Bytes())
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	out.Write(encodeMMDB(map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               dbType,
+		"description":                 map[string]any{},
+		"ip_version":                  uint16(4),
+		"languages":                   []any{},
+		"node_count":                  uint32(len(nodes)),
+		"record_size":                 uint16(24),
+	}))
+
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func country(iso string) map[string]any {
+	return map[string]any{"country": map[string]any{"iso_code": iso}}
+}
+
+func asn(number uint32, org string) map[string]any {
+	return map[string]any{
+		"autonomous_system_number":       number,
+		"autonomous_system_organization": org,
+	}
+}
+
+func testResolver(t *testing.T) (*Resolver, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	countryPath := filepath.Join(dir, "country.mmdb")
+	asnPath := filepath.Join(dir, "asn.mmdb")
+
+	writeMMDB(t, countryPath, "GeoLite2-Country", map[string]map[string]any{
+		"203.0.113.0/24":  country("DE"),
+		"198.51.100.0/24": country("NL"),
+	})
+	writeMMDB(t, asnPath, "GeoLite2-ASN", map[string]map[string]any{
+		"203.0.113.0/24": asn(64500, "Example Net"),
+	})
+
+	r, err := NewResolver(countryPath, asnPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+
+	return r, countryPath
+}
+
+func TestNewResolverNoDatabase(t *testing.T) {
+	if _, err := NewResolver("", ""); err != ErrNoDatabase {
+		t.Fatalf("expected ErrNoDatabase, got %v", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	r, _ := testResolver(t)
+
+	cases := []struct {
+		name string
+		addr string
+		want Location
+	}{
+		{"country and asn", "203.0.113.7", Location{Country: "DE", ASN: "AS64500", ASOrg: "Example Net"}},
+		{"country only", "198.51.100.1", Location{Country: "NL", ASN: Unknown}},
+		{"private", "10.0.0.1", Location{Country: Unknown, ASN: Unknown}},
+		{"loopback", "127.0.0.1", Location{Country: Unknown, ASN: Unknown}},
+		{"ipv6 in ipv4 database", "2001:db8::1", Location{Country: Unknown, ASN: Unknown}},
+		{"not an address", "example.com", Location{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := r.Lookup(c.addr); got != c.want {
+				t.Fatalf("Lookup(%q) = %+v, want %+v", c.addr, got, c.want)
+			}
+		})
+	}
+}
+
+func TestLookupSingleDatabase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "asn.mmdb")
+	writeMMDB(t, path, "GeoLite2-ASN", map[string]map[string]any{
+		"203.0.113.0/24": asn(64500, "Example Net"),
+	})
+
+	r, err := NewResolver("", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	want := Location{ASN: "AS64500", ASOrg: "Example Net"}
+	if got := r.Lookup("203.0.113.7"); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestWatchReload(t *testing.T) {
+	delay := reloadDelay
+	reloadDelay = 50 * time.Millisecond
+	defer func() { reloadDelay = delay }()
+
+	r, path := testResolver(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Watch(ctx, func(err error) { t.Errorf("watch: %v", err) })
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// give watcher time to register directory
+	time.Sleep(100 * time.Millisecond)
+
+	// in place write is not followed, file may be half written
+	writeMMDB(t, path, "GeoLite2-Country", map[string]map[string]any{
+		"203.0.113.0/24": country("FR"),
+	})
+	time.Sleep(5 * reloadDelay)
+	if got := r.Lookup("203.0.113.7").Country; got != "DE" {
+		t.Fatalf("reloaded on in place write, country %q", got)
+	}
+
+	// atomic replace
+	tmp := filepath.Join(filepath.Dir(path), "country.mmdb.tmp")
+	writeMMDB(t, tmp, "GeoLite2-Country", map[string]map[string]any{
+		"203.0.113.0/24": country("PL"),
+	})
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Lookup("203.0.113.7").Country != "PL" {
+		if time.Now().After(deadline) {
+			t.Fatalf("not reloaded after atomic replace, country %q", r.Lookup("203.0.113.7").Country)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := r.Lookup("198.51.100.1").Country; got != Unknown {
+		t.Fatalf("old network still resolved after reload: %q", got)
+	}
+	if got := r.Lookup("203.0.113.7").ASN; got != "AS64500" {
+		t.Fatalf("asn database changed: %q", got)
+	}
+}
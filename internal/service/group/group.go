@@ -0,0 +1,106 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Mode string
+
+const (
+	ModeNone   Mode = "none"
+	ModePrefix Mode = "prefix"
+	ModeSuffix Mode = "suffix"
+)
+
+const (
+	defaultSeparator = "-"
+	// Ungrouped - group of clients not matched by mapping or naming convention
+	Ungrouped = "ungrouped"
+)
+
+var (
+	ErrUnknownMode = errors.New("unknown client group mode")
+)
+
+// Grouper - derives client group from email naming convention or mapping file
+type Grouper struct {
+	mode      Mode
+	separator string
+	mapping   map[string]string
+}
+
+// NewGrouper - creates client grouper. Mapping file entries take precedence over naming convention
+func NewGrouper(mode, separator, mappingFile string) (*Grouper, error) {
+
+	g := &Grouper{
+		mode:      Mode(mode),
+		separator: separator,
+		mapping:   map[string]string{},
+	}
+
+	if g.separator == "" {
+		g.separator = defaultSeparator
+	}
+
+	switch g.mode {
+	case "":
+		g.mode = ModeNone
+	case ModeNone, ModePrefix, ModeSuffix:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMode, mode)
+	}
+
+	if mappingFile != "" {
+		mapping, err := readMapping(mappingFile)
+		if err != nil {
+			return nil, err
+		}
+		g.mapping = mapping
+	}
+
+	return g, nil
+}
+
+// Enabled - false if clients are not grouped at all
+func (g *Grouper) Enabled() bool {
+	return g != nil && (g.mode != ModeNone || len(g.mapping) > 0)
+}
+
+// Group - returns client group name
+func (g *Grouper) Group(email string) string {
+	if group, ok := g.mapping[email]; ok {
+		return group
+	}
+
+	switch g.mode {
+	case ModePrefix:
+		if idx := strings.Index(email, g.separator); idx > 0 {
+			return email[:idx]
+		}
+	case ModeSuffix:
+		if idx := strings.LastIndex(email, g.separator); idx >= 0 && idx+len(g.separator) < len(email) {
+			return email[idx+len(g.separator):]
+		}
+	}
+
+	return Ungrouped
+}
+
+func readMapping(file string) (map[string]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read group mapping file: %w", err)
+	}
+
+	mapping := map[string]string{}
+	if err := yaml.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse group mapping file: %w", err)
+	}
+
+	return mapping, nil
+}
@@ -0,0 +1,105 @@
+package group
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		separator string
+		email     string
+		want      string
+	}{
+		{"prefix", "prefix", "", "acme-alice", "acme"},
+		{"prefix takes first separator", "prefix", "", "acme-eu-alice", "acme"},
+		{"prefix without separator", "prefix", "", "alice", Ungrouped},
+		{"prefix with empty first part", "prefix", "", "-alice", Ungrouped},
+		{"prefix with empty rest", "prefix", "", "acme-", "acme"},
+		{"prefix custom separator", "prefix", ".", "acme.alice-1", "acme"},
+		{"prefix multi character separator", "prefix", "::", "acme::alice", "acme"},
+		{"suffix", "suffix", "@", "alice@acme", "acme"},
+		{"suffix takes last separator", "suffix", "@", "alice@eu@acme", "acme"},
+		{"suffix without separator", "suffix", "@", "alice", Ungrouped},
+		{"suffix with empty last part", "suffix", "@", "alice@", Ungrouped},
+		{"suffix with empty first part", "suffix", "@", "@acme", "acme"},
+		{"suffix multi character separator", "suffix", "--", "alice--acme", "acme"},
+		{"none mode", "none", "", "acme-alice", Ungrouped},
+		{"empty email", "prefix", "", "", Ungrouped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := NewGrouper(tt.mode, tt.separator, "")
+			if err != nil {
+				t.Fatalf("NewGrouper() error = %v", err)
+			}
+			if got := g.Group(tt.email); got != tt.want {
+				t.Errorf("Group(%q) = %q, want %q", tt.email, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupMapping(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "groups.yaml")
+	data := "acme-alice: vip\nbob: family\n"
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		mode  string
+		email string
+		want  string
+	}{
+		{"prefix", "acme-alice", "vip"},
+		{"prefix", "acme-carol", "acme"},
+		{"prefix", "bob", "family"},
+		{"prefix", "dave", Ungrouped},
+		{"suffix", "acme-alice", "vip"},
+		{"suffix", "carol-acme", "acme"},
+		{"none", "bob", "family"},
+		{"none", "acme-carol", Ungrouped},
+	}
+
+	for _, tt := range tests {
+		g, err := NewGrouper(tt.mode, "", file)
+		if err != nil {
+			t.Fatalf("NewGrouper() error = %v", err)
+		}
+		if got := g.Group(tt.email); got != tt.want {
+			t.Errorf("%s Group(%q) = %q, want %q", tt.mode, tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	var nilGrouper *Grouper
+	if nilGrouper.Enabled() {
+		t.Error("nil Grouper is enabled")
+	}
+
+	none, _ := NewGrouper("", "", "")
+	if none.Enabled() {
+		t.Error("Grouper without mode and mapping is enabled")
+	}
+
+	prefix, _ := NewGrouper("prefix", "", "")
+	if !prefix.Enabled() {
+		t.Error("prefix Grouper is not enabled")
+	}
+}
+
+func TestNewGrouperErrors(t *testing.T) {
+	if _, err := NewGrouper("domain", "", ""); !errors.Is(err, ErrUnknownMode) {
+		t.Errorf("NewGrouper() error = %v, want %v", err, ErrUnknownMode)
+	}
+	if _, err := NewGrouper("prefix", "", filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("NewGrouper() accepted missing mapping file")
+	}
+}
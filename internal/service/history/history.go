@@ -0,0 +1,282 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eterline/x3ui-exporter/internal/service/scrape"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+const cleanupInterval = time.Hour
+
+var (
+	ErrBadPeriod = errors.New("unknown history period")
+)
+
+// Retention - how long rollups of each period are kept, 0 keeps forever
+type Retention struct {
+	Hourly  time.Duration
+	Daily   time.Duration
+	Monthly time.Duration
+}
+
+func (r Retention) of(p Period) time.Duration {
+	switch p {
+	case Hourly:
+		return r.Hourly
+	case Daily:
+		return r.Daily
+	case Monthly:
+		return r.Monthly
+	}
+	return 0
+}
+
+// History - embedded per client traffic history with hourly, daily and monthly rollups
+type History struct {
+	db        *gorm.DB
+	retention Retention
+	mu        sync.Mutex
+}
+
+// Open - opens or creates history database file
+func Open(path string, retention Retention, log logger.Interface) (*History, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)", path)
+
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: log})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+
+	if err := db.AutoMigrate(&TrafficRecord{}, &TrafficCursor{}, &NotifyMark{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate history database: %w", err)
+	}
+
+	h := &History{
+		db:        db,
+		retention: retention,
+	}
+
+	return h, nil
+}
+
+func (h *History) Close() error {
+	db, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
+// Record - stores traffic increase of panel accumulated client stats since previous record.
+// Panel side resets are detected, clients seen first time only set the increase base
+func (h *History) Record(ctx context.Context, panel string, stats []scrape.ClientStat, at time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+
+		cursors := []TrafficCursor{}
+		if err := tx.Where("panel = ?", panel).Find(&cursors).Error; err != nil {
+			return err
+		}
+
+		last := make(map[[2]string]TrafficCursor, len(cursors))
+		for _, c := range cursors {
+			last[[2]string{c.Inbound, c.Email}] = c
+		}
+
+		changed := []TrafficCursor{}
+		records := []TrafficRecord{}
+
+		for _, st := range stats {
+			cur := TrafficCursor{Panel: panel, Inbound: st.Name, Email: st.Email, Up: st.Up, Down: st.Down}
+
+			prev, ok := last[[2]string{st.Name, st.Email}]
+			if ok && prev.Up == cur.Up && prev.Down == cur.Down {
+				continue
+			}
+			changed = append(changed, cur)
+
+			if !ok {
+				continue
+			}
+
+			up, down := increase(prev.Up, cur.Up), increase(prev.Down, cur.Down)
+			if up == 0 && down == 0 {
+				continue
+			}
+
+			for _, p := range Periods {
+				records = append(records, TrafficRecord{
+					Period:   p,
+					Start:    p.Start(at),
+					Panel:    panel,
+					Inbound:  st.Name,
+					Email:    st.Email,
+					Protocol: st.Protocol,
+					Up:       up,
+					Down:     down,
+					Quota:    st.Total,
+				})
+			}
+		}
+
+		if len(changed) > 0 {
+			err := tx.Clauses(clause.OnConflict{UpdateAll: true}).
+				CreateInBatches(changed, 500).Error
+			if err != nil {
+				return fmt.Errorf("failed to save traffic cursors: %w", err)
+			}
+		}
+
+		if len(records) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{
+					{Name: "period"}, {Name: "start"}, {Name: "panel"}, {Name: "inbound"}, {Name: "email"},
+				},
+				DoUpdates: clause.Assignments(map[string]any{
+					"up":       gorm.Expr("up + excluded.up"),
+					"down":     gorm.Expr("down + excluded.down"),
+					"protocol": gorm.Expr("excluded.protocol"),
+					"quota":    gorm.Expr("excluded.quota"),
+				}),
+			}).CreateInBatches(records, 500).Error
+			if err != nil {
+				return fmt.Errorf("failed to save traffic records: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// increase - counter increase, value lower than previous one means panel side reset
+func increase(prev, cur uint64) uint64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// Query - history records filter, empty fields match everything
+type Query struct {
+	Period  Period
+	From    time.Time
+	To      time.Time
+	Panel   string
+	Inbound string
+	Email   string
+}
+
+// Records - returns rollups of period with bucket start in [From, To)
+func (h *History) Records(ctx context.Context, q Query) ([]TrafficRecord, error) {
+	if !q.Period.Valid() {
+		return nil, fmt.Errorf("%w: %s", ErrBadPeriod, q.Period)
+	}
+
+	tx := h.db.WithContext(ctx).Where("period = ?", q.Period)
+
+	if !q.From.IsZero() {
+		tx = tx.Where("start >= ?", q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		tx = tx.Where("start < ?", q.To.UTC())
+	}
+	if q.Panel != "" {
+		tx = tx.Where("panel = ?", q.Panel)
+	}
+	if q.Inbound != "" {
+		tx = tx.Where("inbound = ?", q.Inbound)
+	}
+	if q.Email != "" {
+		tx = tx.Where("email = ?", q.Email)
+	}
+
+	records := []TrafficRecord{}
+	err := tx.Order("start, panel, inbound, email").Find(&records).Error
+
+	return records, err
+}
+
+// NotifyMarks - returns reported notification conditions of panel
+func (h *History) NotifyMarks(ctx context.Context, panel string) ([]string, error) {
+	keys := []string{}
+	err := h.db.WithContext(ctx).Model(&NotifyMark{}).
+		Where("panel = ?", panel).Order("key").Pluck("key", &keys).Error
+
+	return keys, err
+}
+
+// SaveNotifyMarks - replaces reported notification conditions of panel
+func (h *History) SaveNotifyMarks(ctx context.Context, panel string, keys []string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("panel = ?", panel).Delete(&NotifyMark{}).Error; err != nil {
+			return err
+		}
+
+		if len(keys) == 0 {
+			return nil
+		}
+
+		marks := make([]NotifyMark, 0, len(keys))
+		for _, key := range keys {
+			marks = append(marks, NotifyMark{Panel: panel, Key: key})
+		}
+
+		if err := tx.CreateInBatches(marks, 500).Error; err != nil {
+			return fmt.Errorf("failed to save notify marks: %w", err)
+		}
+		return nil
+	})
+}
+
+// Cleanup - removes rollups older than retention
+func (h *History) Cleanup(ctx context.Context, now time.Time) (int64, error) {
+	var deleted int64
+
+	for _, p := range Periods {
+		keep := h.retention.of(p)
+		if keep <= 0 {
+			continue
+		}
+
+		res := h.db.WithContext(ctx).
+			Where("period = ? AND start < ?", p, now.Add(-keep).UTC()).
+			Delete(&TrafficRecord{})
+		if res.Error != nil {
+			return deleted, res.Error
+		}
+		deleted += res.RowsAffected
+	}
+
+	return deleted, nil
+}
+
+// RunCleanup - removes expired rollups periodically until context is done
+func (h *History) RunCleanup(ctx context.Context, onErr func(error)) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := h.Cleanup(ctx, time.Now()); err != nil && onErr != nil {
+			onErr(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
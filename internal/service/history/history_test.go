@@ -0,0 +1,255 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/eterline/x3ui-exporter/internal/service/scrape"
+	"gorm.io/gorm/logger"
+)
+
+func openTest(t *testing.T, path string, retention Retention) *History {
+	t.Helper()
+
+	h, err := Open(path, retention, logger.Discard)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+
+	return h
+}
+
+func stat(inbound, email string, up, down uint64) scrape.ClientStat {
+	return scrape.ClientStat{Name: inbound, Protocol: "vless", Email: email, Up: up, Down: down, Total: 1 << 30}
+}
+
+func records(t *testing.T, h *History, q Query) []TrafficRecord {
+	t.Helper()
+
+	recs, err := h.Records(context.Background(), q)
+	if err != nil {
+		t.Fatalf("Records() error = %v", err)
+	}
+	return recs
+}
+
+func record(t *testing.T, h *History, panel string, at time.Time, stats ...scrape.ClientStat) {
+	t.Helper()
+
+	if err := h.Record(context.Background(), panel, stats, at); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+}
+
+func TestRecordIncrease(t *testing.T) {
+	h := openTest(t, filepath.Join(t.TempDir(), "history.db"), Retention{})
+	at := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	// first sample is only the base, panel lifetime traffic is not an increase
+	record(t, h, "p", at, stat("vless", "alice", 1000, 5000))
+	if recs := records(t, h, Query{Period: Daily}); len(recs) != 0 {
+		t.Fatalf("Records() after first sample = %+v, want none", recs)
+	}
+
+	record(t, h, "p", at.Add(time.Minute), stat("vless", "alice", 1100, 5300))
+	record(t, h, "p", at.Add(2*time.Minute), stat("vless", "alice", 1100, 5300))
+
+	// panel reset counters, traffic after reset is the increase
+	record(t, h, "p", at.Add(3*time.Minute), stat("vless", "alice", 40, 70))
+
+	recs := records(t, h, Query{Period: Daily})
+	if len(recs) != 1 {
+		t.Fatalf("Records() = %+v, want single daily record", recs)
+	}
+	if r := recs[0]; r.Up != 140 || r.Down != 370 || r.Quota != 1<<30 || r.Protocol != "vless" {
+		t.Errorf("daily record = %+v, want up 140 down 370", r)
+	}
+}
+
+func TestRecordRollups(t *testing.T) {
+	h := openTest(t, filepath.Join(t.TempDir(), "history.db"), Retention{})
+
+	// local times around UTC month, day and hour boundaries
+	zone := time.FixedZone("UTC+3", 3*3600)
+	base := time.Date(2025, 2, 1, 2, 30, 0, 0, zone) // 2025-01-31 23:30 UTC
+
+	record(t, h, "p", base, stat("vless", "alice", 0, 0))
+	record(t, h, "p", base.Add(20*time.Minute), stat("vless", "alice", 10, 0))   // 23:50 UTC Jan 31
+	record(t, h, "p", base.Add(40*time.Minute), stat("vless", "alice", 30, 0))   // 00:10 UTC Feb 1
+	record(t, h, "p", base.Add(100*time.Minute), stat("vless", "alice", 60, 0))  // 01:10 UTC Feb 1
+	record(t, h, "p", base.Add(110*time.Minute), stat("vless", "alice", 100, 0)) // 01:20 UTC Feb 1
+
+	tests := []struct {
+		period Period
+		want   map[string]uint64
+	}{
+		{Hourly, map[string]uint64{
+			"2025-01-31T23:00:00Z": 10,
+			"2025-02-01T00:00:00Z": 20,
+			"2025-02-01T01:00:00Z": 70,
+		}},
+		{Daily, map[string]uint64{
+			"2025-01-31T00:00:00Z": 10,
+			"2025-02-01T00:00:00Z": 90,
+		}},
+		{Monthly, map[string]uint64{
+			"2025-01-01T00:00:00Z": 10,
+			"2025-02-01T00:00:00Z": 90,
+		}},
+	}
+
+	for _, tt := range tests {
+		got := map[string]uint64{}
+		for _, r := range records(t, h, Query{Period: tt.period}) {
+			got[r.Start.UTC().Format(time.RFC3339)] = r.Up
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s records = %v, want %v", tt.period, got, tt.want)
+			continue
+		}
+		for start, up := range tt.want {
+			if got[start] != up {
+				t.Errorf("%s record %s up = %d, want %d", tt.period, start, got[start], up)
+			}
+		}
+	}
+}
+
+func TestRecordsFilter(t *testing.T) {
+	h := openTest(t, filepath.Join(t.TempDir(), "history.db"), Retention{})
+	at := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	for i, panel := range []string{"p1", "p2"} {
+		n := uint64(i + 1)
+		record(t, h, panel, at,
+			stat("vless", "alice", 0, 0), stat("vless", "bob", 0, 0), stat("trojan", "alice", 0, 0))
+		record(t, h, panel, at.Add(time.Minute),
+			stat("vless", "alice", n, 0), stat("vless", "bob", 10*n, 0), stat("trojan", "alice", 100*n, 0))
+	}
+
+	tests := []struct {
+		name string
+		q    Query
+		want []uint64
+	}{
+		{"everything", Query{Period: Daily}, []uint64{1, 2, 10, 20, 100, 200}},
+		{"panel", Query{Period: Daily, Panel: "p2"}, []uint64{2, 20, 200}},
+		{"inbound", Query{Period: Daily, Inbound: "vless"}, []uint64{1, 2, 10, 20}},
+		{"email", Query{Period: Daily, Email: "alice"}, []uint64{1, 2, 100, 200}},
+		{"all filters", Query{Period: Daily, Panel: "p1", Inbound: "trojan", Email: "alice"}, []uint64{100}},
+		{"range before records", Query{Period: Daily, To: at.Add(-24 * time.Hour)}, []uint64{}},
+		{"range including day", Query{Period: Daily, From: Daily.Start(at), To: Daily.Start(at).Add(24 * time.Hour)}, []uint64{1, 2, 10, 20, 100, 200}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []uint64{}
+			for _, r := range records(t, h, tt.q) {
+				got = append(got, r.Up)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Records() up = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := h.Records(context.Background(), Query{Period: "week"}); !errors.Is(err, ErrBadPeriod) {
+		t.Errorf("Records() error = %v, want %v", err, ErrBadPeriod)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		retention Retention
+		// left - records of hourly, daily and monthly periods after cleanup
+		left map[Period]int
+	}{
+		{"zero keeps forever", Retention{}, map[Period]int{Hourly: 2, Daily: 2, Monthly: 2}},
+		{"hourly", Retention{Hourly: 48 * time.Hour}, map[Period]int{Hourly: 1, Daily: 2, Monthly: 2}},
+		{"daily", Retention{Daily: 30 * 24 * time.Hour}, map[Period]int{Hourly: 2, Daily: 1, Monthly: 2}},
+		{"monthly", Retention{Monthly: 60 * 24 * time.Hour}, map[Period]int{Hourly: 2, Daily: 2, Monthly: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := openTest(t, filepath.Join(t.TempDir(), "history.db"), tt.retention)
+
+			// traffic of 100 days ago, then panel reset and traffic of last hour
+			old, recent := now.Add(-100*24*time.Hour), now.Add(-time.Hour)
+			record(t, h, "p", old, stat("vless", "alice", 0, 0))
+			record(t, h, "p", old.Add(time.Minute), stat("vless", "alice", 1, 0))
+			record(t, h, "p", recent, stat("vless", "alice", 0, 0))
+			record(t, h, "p", recent.Add(time.Minute), stat("vless", "alice", 1, 0))
+
+			for p, want := range map[Period]int{Hourly: 2, Daily: 2, Monthly: 2} {
+				if got := len(records(t, h, Query{Period: p})); got != want {
+					t.Fatalf("%s records before cleanup = %d, want %d", p, got, want)
+				}
+			}
+
+			deleted, err := h.Cleanup(context.Background(), now)
+			if err != nil {
+				t.Fatalf("Cleanup() error = %v", err)
+			}
+
+			total := int64(0)
+			for p, want := range tt.left {
+				got := len(records(t, h, Query{Period: p}))
+				if got != want {
+					t.Errorf("%s records after cleanup = %d, want %d", p, got, want)
+				}
+				total += int64(2 - got)
+			}
+			if deleted != total {
+				t.Errorf("Cleanup() deleted = %d, want %d", deleted, total)
+			}
+		})
+	}
+}
+
+func TestNotifyMarks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	ctx := context.Background()
+
+	h, err := Open(path, Retention{}, logger.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.SaveNotifyMarks(ctx, "p1", []string{"b", "a"}); err != nil {
+		t.Fatalf("SaveNotifyMarks() error = %v", err)
+	}
+	if err := h.SaveNotifyMarks(ctx, "p2", []string{"c"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SaveNotifyMarks(ctx, "p1", []string{"b", "d"}); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+
+	// marks survive reopen, latest save replaces panel marks
+	h = openTest(t, path, Retention{})
+
+	if got, err := h.NotifyMarks(ctx, "p1"); err != nil || !slices.Equal(got, []string{"b", "d"}) {
+		t.Errorf("NotifyMarks(p1) = %v, %v, want [b d]", got, err)
+	}
+	if got, _ := h.NotifyMarks(ctx, "p2"); !slices.Equal(got, []string{"c"}) {
+		t.Errorf("NotifyMarks(p2) = %v, want [c]", got)
+	}
+
+	if err := h.SaveNotifyMarks(ctx, "p1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := h.NotifyMarks(ctx, "p1"); len(got) != 0 {
+		t.Errorf("NotifyMarks(p1) after clear = %v, want none", got)
+	}
+}
@@ -0,0 +1,68 @@
+package history
+
+import "time"
+
+type Period string
+
+const (
+	Hourly  Period = "hour"
+	Daily   Period = "day"
+	Monthly Period = "month"
+)
+
+// Periods - every rollup period stored in history
+var Periods = []Period{Hourly, Daily, Monthly}
+
+// Start - returns period bucket start of time in UTC
+func (p Period) Start(t time.Time) time.Time {
+	t = t.UTC()
+
+	switch p {
+	case Hourly:
+		return t.Truncate(time.Hour)
+	case Daily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case Monthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return t
+	}
+}
+
+// Valid - true if period is known
+func (p Period) Valid() bool {
+	switch p {
+	case Hourly, Daily, Monthly:
+		return true
+	}
+	return false
+}
+
+// TrafficRecord - client traffic increase in one period bucket
+type TrafficRecord struct {
+	ID       uint      `gorm:"primaryKey" json:"-"`
+	Period   Period    `gorm:"size:8;uniqueIndex:idx_record_key,priority:1" json:"period"`
+	Start    time.Time `gorm:"uniqueIndex:idx_record_key,priority:2;index" json:"start"`
+	Panel    string    `gorm:"uniqueIndex:idx_record_key,priority:3" json:"panel"`
+	Inbound  string    `gorm:"uniqueIndex:idx_record_key,priority:4" json:"inbound"`
+	Email    string    `gorm:"uniqueIndex:idx_record_key,priority:5;index" json:"email"`
+	Protocol string    `json:"protocol"`
+	Up       uint64    `json:"up"`
+	Down     uint64    `json:"down"`
+	Quota    uint64    `json:"quota"`
+}
+
+// TrafficCursor - last seen panel accumulated client traffic, base for next increment
+type TrafficCursor struct {
+	Panel   string `gorm:"primaryKey"`
+	Inbound string `gorm:"primaryKey"`
+	Email   string `gorm:"primaryKey"`
+	Up      uint64
+	Down    uint64
+}
+
+// NotifyMark - notification condition already reported, keeps dedupe across restarts
+type NotifyMark struct {
+	Panel string `gorm:"primaryKey"`
+	Key   string `gorm:"primaryKey"`
+}
@@ -0,0 +1,220 @@
+package identity
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/eterline/x3ui-exporter/pkg/toolkit"
+	"gopkg.in/yaml.v3"
+)
+
+type Mode string
+
+const (
+	ModeRaw   Mode = "raw"
+	ModeHash  Mode = "hash"
+	ModeAlias Mode = "alias"
+)
+
+var (
+	ErrUnknownMode   = errors.New("unknown identity mode")
+	ErrAliasFileNeed = errors.New("alias identity mode requires aliases file")
+	ErrSaltNeed      = errors.New("hash identity mode requires salt")
+	ErrNotLoopback   = errors.New("identity lookup must listen on loopback address")
+)
+
+// Resolver - maps client emails to label identities and back
+type Resolver struct {
+	mode    Mode
+	salt    string
+	aliases map[string]string
+
+	reverse map[string]string
+	mu      sync.RWMutex
+}
+
+// NewResolver - creates identity resolver.
+// In alias mode clients absent in aliases file are hashed, so both modes require salt:
+// unsalted hash of known email is trivially reversible
+func NewResolver(mode, salt, aliasFile string) (*Resolver, error) {
+
+	r := &Resolver{
+		mode:    Mode(mode),
+		salt:    salt,
+		aliases: map[string]string{},
+		reverse: map[string]string{},
+	}
+
+	switch r.mode {
+	case "":
+		r.mode = ModeRaw
+	case ModeRaw:
+	case ModeHash:
+		if salt == "" {
+			return nil, ErrSaltNeed
+		}
+	case ModeAlias:
+		if salt == "" {
+			return nil, ErrSaltNeed
+		}
+		if aliasFile == "" {
+			return nil, ErrAliasFileNeed
+		}
+
+		aliases, err := readAliases(aliasFile)
+		if err != nil {
+			return nil, err
+		}
+
+		r.aliases = aliases
+		for email, alias := range aliases {
+			r.reverse[alias] = email
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMode, mode)
+	}
+
+	return r, nil
+}
+
+// Mode - current identity mode
+func (r *Resolver) Mode() Mode {
+	return r.mode
+}
+
+// Identity - returns label value for client email
+func (r *Resolver) Identity(email string) string {
+	if r.mode == ModeRaw || email == "" {
+		return email
+	}
+
+	if alias, ok := r.aliases[email]; ok {
+		return alias
+	}
+
+	id := r.hash(email)
+
+	r.mu.RLock()
+	_, known := r.reverse[id]
+	r.mu.RUnlock()
+
+	if !known {
+		r.mu.Lock()
+		r.reverse[id] = email
+		r.mu.Unlock()
+	}
+
+	return id
+}
+
+// Lookup - returns client email for identity seen before
+func (r *Resolver) Lookup(id string) (string, bool) {
+	if r.mode == ModeRaw {
+		return id, true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	email, ok := r.reverse[id]
+	return email, ok
+}
+
+func (r *Resolver) hash(email string) string {
+	id, ok := toolkit.StringUUID(r.salt + email)
+	if !ok {
+		return ""
+	}
+	return id.String()
+}
+
+type lookupResponse struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+}
+
+// Handler - identity reverse lookup handler, answers only to loopback peers with bearer token
+func (r *Resolver) Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !loopback(req.RemoteAddr) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if !authorized(req, token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		id := req.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id query parameter required", http.StatusBadRequest)
+			return
+		}
+
+		email, ok := r.Lookup(id)
+		if !ok {
+			http.Error(w, "identity not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lookupResponse{ID: id, Email: email})
+	})
+}
+
+// CheckListen - returns error if lookup listen address is reachable from other hosts
+func CheckListen(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNotLoopback, err)
+	}
+
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%w: %s", ErrNotLoopback, addr)
+	}
+	return nil
+}
+
+func loopback(remote string) bool {
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func authorized(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func readAliases(file string) (map[string]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read aliases file: %w", err)
+	}
+
+	aliases := map[string]string{}
+	if err := yaml.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("failed to parse aliases file: %w", err)
+	}
+
+	return aliases, nil
+}
@@ -0,0 +1,167 @@
+package identity
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func aliasFile(t *testing.T) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "aliases.yaml")
+	if err := os.WriteFile(file, []byte("alice@vpn.example: alice\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestNewResolverErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		mode, salt, file string
+		want             error
+	}{
+		{"hash without salt", "hash", "", "", ErrSaltNeed},
+		{"alias without salt", "alias", "", "aliases.yaml", ErrSaltNeed},
+		{"alias without file", "alias", "s", "", ErrAliasFileNeed},
+		{"unknown mode", "encrypt", "s", "", ErrUnknownMode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewResolver(tt.mode, tt.salt, tt.file); !errors.Is(err, tt.want) {
+				t.Errorf("NewResolver() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	r, err := NewResolver("", "", "")
+	if err != nil || r.Mode() != ModeRaw {
+		t.Errorf("NewResolver() empty mode = %v, %v, want raw", r, err)
+	}
+}
+
+func TestHashIdentity(t *testing.T) {
+	a, _ := NewResolver("hash", "salt-a", "")
+	again, _ := NewResolver("hash", "salt-a", "")
+	b, _ := NewResolver("hash", "salt-b", "")
+
+	id := a.Identity("alice@vpn.example")
+	if id == "" || id == "alice@vpn.example" {
+		t.Fatalf("Identity() = %q, want hash", id)
+	}
+	if got := again.Identity("alice@vpn.example"); got != id {
+		t.Errorf("Identity() with same salt = %q, want %q", got, id)
+	}
+	if got := b.Identity("alice@vpn.example"); got == id {
+		t.Errorf("Identity() with other salt = %q, want different hash", got)
+	}
+	if got := a.Identity("bob@vpn.example"); got == id {
+		t.Errorf("Identity() of other email = %q, want different hash", got)
+	}
+	if got := a.Identity(""); got != "" {
+		t.Errorf("Identity() of empty email = %q", got)
+	}
+
+	if email, ok := a.Lookup(id); !ok || email != "alice@vpn.example" {
+		t.Errorf("Lookup() = %q, %v", email, ok)
+	}
+	if _, ok := b.Lookup(id); ok {
+		t.Error("Lookup() found identity never seen by resolver")
+	}
+}
+
+func TestAliasIdentity(t *testing.T) {
+	r, err := NewResolver("alias", "salt", aliasFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed, _ := NewResolver("hash", "salt", "")
+
+	if got := r.Identity("alice@vpn.example"); got != "alice" {
+		t.Errorf("Identity() aliased = %q, want alice", got)
+	}
+	if got, want := r.Identity("bob@vpn.example"), hashed.Identity("bob@vpn.example"); got != want {
+		t.Errorf("Identity() without alias = %q, want salted hash %q", got, want)
+	}
+
+	if email, ok := r.Lookup("alice"); !ok || email != "alice@vpn.example" {
+		t.Errorf("Lookup() alias = %q, %v", email, ok)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r, _ := NewResolver("hash", "salt", "")
+	id := r.Identity("alice@vpn.example")
+	h := r.Handler("t0ken")
+
+	tests := []struct {
+		name   string
+		remote string
+		auth   string
+		query  string
+		code   int
+	}{
+		{"found", "127.0.0.1:5000", "Bearer t0ken", "?id=" + id, http.StatusOK},
+		{"ipv6 loopback", "[::1]:5000", "Bearer t0ken", "?id=" + id, http.StatusOK},
+		{"unknown identity", "127.0.0.1:5000", "Bearer t0ken", "?id=nobody", http.StatusNotFound},
+		{"missing id", "127.0.0.1:5000", "Bearer t0ken", "", http.StatusBadRequest},
+		{"missing token", "127.0.0.1:5000", "", "?id=" + id, http.StatusUnauthorized},
+		{"wrong token", "127.0.0.1:5000", "Bearer nope", "?id=" + id, http.StatusUnauthorized},
+		{"remote peer with token", "203.0.113.7:5000", "Bearer t0ken", "?id=" + id, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/identity"+tt.query, nil)
+			req.RemoteAddr = tt.remote
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.code)
+			}
+			if tt.code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Error("401 without WWW-Authenticate challenge")
+			}
+			if tt.code == http.StatusOK {
+				resp := lookupResponse{}
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Email != "alice@vpn.example" || resp.ID != id {
+					t.Errorf("response = %+v, %v", resp, err)
+				}
+			}
+		})
+	}
+
+	// empty token never authorizes
+	req := httptest.NewRequest(http.MethodGet, "/identity?id="+id, nil)
+	req.RemoteAddr = "127.0.0.1:5000"
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	r.Handler("").ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status with empty token = %d, want 401", rec.Code)
+	}
+}
+
+func TestCheckListen(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:4501", "[::1]:4501", "localhost:4501"} {
+		if err := CheckListen(addr); err != nil {
+			t.Errorf("CheckListen(%q) error = %v", addr, err)
+		}
+	}
+	for _, addr := range []string{":4501", "0.0.0.0:4501", "10.0.0.1:4501", "example.com:4501", "127.0.0.1"} {
+		if err := CheckListen(addr); !errors.Is(err, ErrNotLoopback) {
+			t.Errorf("CheckListen(%q) error = %v, want %v", addr, err, ErrNotLoopback)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package metrics
+
+const (
+	labelResult      = "result"
+	labelDestination = "destination"
+	labelWindow      = "window"
+	labelCountry     = "country"
+	labelASN         = "asn"
+	labelASOrg       = "as_org"
+
+	resultAccepted = "accepted"
+	resultRejected = "rejected"
+)
+
+// accessMetrics - Xray access log stats, exported only with access log tailing enabled
+type accessMetrics struct {
+	Connections       *family
+	ClientConnections *family
+	ClientSourceIPs   *family
+	ClientIPLimit     *family
+	ClientIPExceeded  *family
+	TopDestinations   *family
+
+	CountryConnections *family
+	ASNConnections     *family
+}
+
+func newAccessMetrics(ns string) *accessMetrics {
+	return &accessMetrics{
+		Connections: newCounterFamily(
+			nsName(ns, "access_connections_total"),
+			"Xray access log connections by result",
+		),
+		ClientConnections: newCounterFamily(
+			nsName(ns, "client_connections_total"),
+			"Xray access log accepted connections per client",
+		),
+		ClientSourceIPs: newGaugeFamily(
+			nsName(ns, "client_source_ips"),
+			"Distinct client source IPs seen in sliding window",
+		),
+		ClientIPLimit: newGaugeFamily(
+			nsName(ns, "client_ip_limit"),
+			"3X-UI client configured distinct IPs limit",
+		),
+		ClientIPExceeded: newGaugeFamily(
+			nsName(ns, "client_ip_limit_exceeded"),
+			"1 if client distinct source IPs in window exceed its limit",
+		),
+		TopDestinations: newGaugeFamily(
+			nsName(ns, "top_destination_connections"),
+			"Estimated connections of most connected destinations since exporter start",
+		),
+
+		CountryConnections: newCounterFamily(
+			nsName(ns, "client_country_connections_total"),
+			"Xray access log accepted connections per source country, also per client if enabled",
+		),
+		ASNConnections: newCounterFamily(
+			nsName(ns, "client_asn_connections_total"),
+			"Xray access log accepted connections per source autonomous system, also per client if enabled",
+		),
+	}
+}
+
+func (am *accessMetrics) families() []*family {
+	return []*family{
+		am.Connections,
+		am.ClientConnections,
+		am.ClientSourceIPs,
+		am.ClientIPLimit,
+		am.ClientIPExceeded,
+		am.TopDestinations,
+		am.CountryConnections,
+		am.ASNConnections,
+	}
+}
+
+// ObserveConnection - counts access log connection
+func (mre *MetricsReg) ObserveConnection(email string, accepted bool) {
+	result := resultRejected
+	if accepted {
+		result = resultAccepted
+	}
+	addMetric(mre, mre.access.Connections, Labels{labelResult: result}, 1)
+
+	if !accepted || email == "" || !mre.opts.clientSeries {
+		return
+	}
+	addMetric(mre, mre.access.ClientConnections, mre.clientLabels(email), 1)
+}
+
+// ObserveGeoConnection - counts accepted connection by source country and ASN, empty ones are skipped.
+// Connections are split per client only with client geo series enabled
+func (mre *MetricsReg) ObserveGeoConnection(email, country, asn, asOrg string) {
+	lset := Labels{}
+
+	if mre.opts.clientGeo {
+		if email == "" || !mre.opts.clientSeries {
+			return
+		}
+		lset = mre.clientLabels(email)
+	}
+
+	if country != "" {
+		addMetric(mre, mre.access.CountryConnections, lset.with(labelCountry, country), 1)
+	}
+	if asn != "" {
+		addMetric(mre, mre.access.ASNConnections, lset.with(labelASN, asn).with(labelASOrg, asOrg), 1)
+	}
+}
+
+// ClientIPs - distinct source IPs of client by window label and its limit, 0 means unlimited
+type ClientIPs struct {
+	Email   string
+	Windows map[string]int
+	Limit   int
+}
+
+// SetClientSourceIPs - replaces distinct source IPs counts, limits and limit exceed flags
+func (mre *MetricsReg) SetClientSourceIPs(clients []ClientIPs) {
+	if !mre.opts.clientSeries {
+		return
+	}
+
+	var ips, limits, exceeded []sample
+
+	for _, cl := range clients {
+		lset := mre.clientLabels(cl.Email)
+
+		if cl.Limit > 0 {
+			limits = append(limits, sample{lset: lset, value: float64(cl.Limit)})
+		}
+
+		for window, n := range cl.Windows {
+			wlset := lset.with(labelWindow, window)
+			ips = append(ips, sample{lset: wlset, value: float64(n)})
+
+			if cl.Limit > 0 {
+				exceeded = append(exceeded, sample{lset: wlset, value: boolFloat(n > cl.Limit)})
+			}
+		}
+	}
+
+	replaceMetrics(mre, mre.access.ClientSourceIPs, ips)
+	replaceMetrics(mre, mre.access.ClientIPLimit, limits)
+	replaceMetrics(mre, mre.access.ClientIPExceeded, exceeded)
+}
+
+// SetTopDestinations - replaces top destinations connections by host
+func (mre *MetricsReg) SetTopDestinations(top map[string]uint64) {
+	samples := make([]sample, 0, len(top))
+	for host, n := range top {
+		samples = append(samples, sample{lset: Labels{labelDestination: host}, value: float64(n)})
+	}
+	replaceMetrics(mre, mre.access.TopDestinations, samples)
+}
+
+func boolFloat(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import "testing"
+
+func TestObserveGeoConnection(t *testing.T) {
+	tests := []struct {
+		name      string
+		clientGeo bool
+		want      map[string]int
+	}{
+		{
+			name: "aggregated by default",
+			want: map[string]int{"xui_client_country_connections_total": 2, "xui_client_asn_connections_total": 1},
+		},
+		{
+			name:      "per client when enabled",
+			clientGeo: true,
+			want:      map[string]int{"xui_client_country_connections_total": 3, "xui_client_asn_connections_total": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mre := NewMetricsReg(nopLogger{}, WithClientGeo(tt.clientGeo))
+
+			mre.ObserveGeoConnection("alice", "DE", "AS3320", "DTAG")
+			mre.ObserveGeoConnection("bob", "DE", "AS3320", "DTAG")
+			mre.ObserveGeoConnection("bob", "NL", "", "")
+			mre.Publish()
+
+			families := gather(t, mre)
+			for name, n := range tt.want {
+				if got := len(families[name].GetMetric()); got != n {
+					t.Errorf("%s series = %d, want %d", name, got, n)
+				}
+			}
+		})
+	}
+}
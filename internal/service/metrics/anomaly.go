@@ -0,0 +1,62 @@
+package metrics
+
+// anomalyMetrics - client traffic spikes, exported only with anomaly detection enabled
+type anomalyMetrics struct {
+	Score    *family
+	Rate     *family
+	Baseline *family
+}
+
+func newAnomalyMetrics(ns string) *anomalyMetrics {
+	return &anomalyMetrics{
+		Score: newGaugeFamily(
+			nsName(ns, "client_traffic_anomaly_score"),
+			"Client traffic rate share of anomaly limit, 1 and above is anomaly",
+		),
+		Rate: newGaugeFamily(
+			nsName(ns, "client_traffic_rate_bytes_per_second"),
+			"Client latest traffic rate in bytes per second",
+		),
+		Baseline: newGaugeFamily(
+			nsName(ns, "client_traffic_baseline_bytes_per_second"),
+			"Client traffic rate baseline in bytes per second",
+		),
+	}
+}
+
+func (am *anomalyMetrics) families() []*family {
+	return []*family{
+		am.Score,
+		am.Rate,
+		am.Baseline,
+	}
+}
+
+// AnomalyScore - client traffic rate against its baseline
+type AnomalyScore struct {
+	Email    string
+	Rate     float64
+	Baseline float64
+	Score    float64
+}
+
+// SetAnomalyScores - replaces client anomaly scores, rates and baselines
+func (mre *MetricsReg) SetAnomalyScores(scores []AnomalyScore) {
+	if !mre.opts.clientSeries {
+		return
+	}
+
+	var score, rate, baseline []sample
+
+	for _, sc := range scores {
+		lset := mre.clientLabels(sc.Email)
+
+		score = append(score, sample{lset: lset, value: sc.Score})
+		rate = append(rate, sample{lset: lset, value: sc.Rate})
+		baseline = append(baseline, sample{lset: lset, value: sc.Baseline})
+	}
+
+	replaceMetrics(mre, mre.anomaly.Score, score)
+	replaceMetrics(mre, mre.anomaly.Rate, rate)
+	replaceMetrics(mre, mre.anomaly.Baseline, baseline)
+}
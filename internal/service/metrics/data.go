@@ -1,13 +1,39 @@
 package metrics
 
 import (
+	"fmt"
 	"net/http"
-	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/eterline/x3ui-exporter/pkg/relabel"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+const (
+	directionUp   = "up"
+	directionDown = "down"
+)
+
+const (
+	labelEmail     = "email"
+	labelDirection = "direction"
+	labelTag       = "tag"
+	labelInbound   = "inbound"
+	labelProtocol  = "protocol"
+	labelName      = "name"
+	labelProto     = "proto"
+	labelGroup     = "group"
+
+	// meta labels - visible only for relabel rules
+	metaEmail         = "__email"
+	metaClientEnable  = "__client_enable"
+	metaInboundEnable = "__inbound_enable"
+
+	exemplarPushID = "push_id"
+)
+
 type Logger interface {
 	Errorf(string, ...interface{})
 	Infof(string, ...interface{})
@@ -15,88 +41,167 @@ type Logger interface {
 }
 
 type MetricsReg struct {
-	log Logger
+	log  Logger
+	opts *MetricsOptions
+
+	ClientTraffic        *family
+	ClientQuota          *family
+	ClientUsed           *family
+	ClientExpiry         *family
+	InboundTraffic       *family
+	OutboundTraffic      *family
+	InboundClientTraffic *family
+
+	ClientResets    *family
+	ClientLastReset *family
+
+	InboundClientsTotal *family
+	ProtocolTotal       *family
+	GroupTotal          *family
 
-	ClientUpStat    *prometheus.GaugeVec
-	ClientDownStat  *prometheus.GaugeVec
-	ClientTotalStat *prometheus.GaugeVec
+	// legacy - old metric names, nil if legacy mode disabled
+	legacy *legacyMetrics
 
-	InboundUpStat   *prometheus.GaugeVec
-	InboundDownStat *prometheus.GaugeVec
+	Exporter *ExporterMetrics
+	xray     *xrayMetrics
+	access   *accessMetrics
+	anomaly  *anomalyMetrics
 
-	InboundAllUpStat   *prometheus.GaugeVec
-	InboundAllDownStat *prometheus.GaugeVec
+	// outboundScraped - outbound traffic is scraped from panel, pushed one is ignored then
+	outboundScraped atomic.Bool
 
 	// =============================
 	Registry *prometheus.Registry
-
-	muClient     sync.RWMutex
-	muInbound    sync.RWMutex
-	muInboundAll sync.RWMutex
+	resets   *resetTracker
+	pushes   *pushTracker
+	families []*family
+	handler  http.Handler
 }
 
-func NewMetricsReg(log Logger) *MetricsReg {
+func NewMetricsReg(log Logger, options ...MetricsOptionFunc) *MetricsReg {
+	opts := mustOptions(options...)
+	ns := opts.namespace
+
+	exporter := newExporterMetrics(ns)
 
 	self := MetricsReg{
 		Registry: prometheus.NewRegistry(),
-
-		ClientUpStat: newGaugeVec(
-			"client_traffic_up",
-			"3X-UI user down stats",
-			[]string{"email"},
+		resets:   newResetTracker(),
+		Exporter: exporter,
+		pushes:   newPushTracker(ns, opts.staleAfter, exporter.ForgetPushSource),
+		xray:     newXrayMetrics(ns),
+		access:   newAccessMetrics(ns),
+		anomaly:  newAnomalyMetrics(ns),
+
+		ClientTraffic: newCounterFamily(
+			nsName(ns, "client_traffic_bytes_total"),
+			"3X-UI client traffic accumulated from panel pushes",
 		),
-		ClientDownStat: newGaugeVec(
-			"client_traffic_down",
-			"3X-UI user up stats",
-			[]string{"email"},
+		ClientQuota: newGaugeFamily(
+			nsName(ns, "client_quota_bytes"),
+			"3X-UI client traffic quota, 0 means unlimited",
 		),
-		ClientTotalStat: newGaugeVec(
-			"client_traffic_total",
-			"3X-UI user total stats",
-			[]string{"email"},
+		ClientUsed: newGaugeFamily(
+			nsName(ns, "client_used_bytes"),
+			"3X-UI client traffic counted against quota, drops on panel traffic reset",
 		),
-
-		InboundUpStat: newGaugeVec(
-			"inbound_traffic_up",
-			"3X-UI inbound up stats",
-			[]string{"tag"},
+		ClientExpiry: newGaugeFamily(
+			nsName(ns, "client_expiry_timestamp_seconds"),
+			"3X-UI client expiry unix time, absent if client never expires",
 		),
-		InboundDownStat: newGaugeVec(
-			"inbound_traffic_down",
-			"3X-UI inbound up stats",
-			[]string{"tag"},
+		InboundTraffic: newCounterFamily(
+			nsName(ns, "inbound_traffic_bytes_total"),
+			"3X-UI inbound traffic accumulated from panel pushes",
+		),
+		OutboundTraffic: newCounterFamily(
+			nsName(ns, "outbound_traffic_bytes_total"),
+			"3X-UI outbound traffic accumulated from panel pushes or scraped from panel",
+		),
+		InboundClientTraffic: newCounterFamily(
+			nsName(ns, "inbound_client_traffic_bytes_total"),
+			"3X-UI client traffic per inbound scraped from panel",
 		),
 
-		InboundAllUpStat: newGaugeVec(
-			"inbound_all_up",
-			"3X-UI inbound up stats",
-			[]string{"name", "proto", "email"},
+		ClientResets: newCounterFamily(
+			nsName(ns, "client_traffic_resets_total"),
+			"3X-UI client traffic resets detected on panel",
+		),
+		ClientLastReset: newGaugeFamily(
+			nsName(ns, "client_traffic_last_reset_timestamp_seconds"),
+			"3X-UI client last traffic reset unix time",
 		),
 
-		InboundAllDownStat: newGaugeVec(
-			"inbound_all_down",
-			"3X-UI inbound up stats",
-			[]string{"name", "proto", "email"},
+		InboundClientsTotal: newCounterFamily(
+			nsName(ns, "inbound_clients_traffic_bytes_total"),
+			"3X-UI summary clients traffic per inbound scraped from panel",
+		),
+		ProtocolTotal: newCounterFamily(
+			nsName(ns, "protocol_traffic_bytes_total"),
+			"3X-UI summary clients traffic per protocol scraped from panel",
+		),
+		GroupTotal: newCounterFamily(
+			nsName(ns, "group_traffic_bytes_total"),
+			"3X-UI summary clients traffic per client group scraped from panel",
 		),
 	}
 
 	self.log = log
+	self.opts = opts
+
+	self.families = []*family{
+		self.ClientTraffic,
+		self.ClientQuota,
+		self.ClientUsed,
+		self.ClientExpiry,
+		self.InboundTraffic,
+		self.OutboundTraffic,
+		self.InboundClientTraffic,
+		self.ClientResets,
+		self.ClientLastReset,
+		self.InboundClientsTotal,
+		self.ProtocolTotal,
+		self.GroupTotal,
+	}
+	self.families = append(self.families, self.xray.families()...)
+	self.families = append(self.families, self.access.families()...)
+	self.families = append(self.families, self.anomaly.families()...)
+
+	if opts.legacy {
+		self.legacy = newLegacyMetrics()
+		self.families = append(self.families, self.legacy.families()...)
+	}
 
-	c := []prometheus.Collector{
-		self.ClientUpStat,
-		self.ClientDownStat,
-		self.ClientTotalStat,
-		self.InboundUpStat,
-		self.InboundDownStat,
-		self.InboundAllUpStat,
-		self.InboundAllDownStat,
+	if opts.dropStale {
+		for _, f := range self.pushFamilies() {
+			f.visible = self.pushFresh
+		}
+		self.OutboundTraffic.visible = func() bool {
+			return self.outboundScraped.Load() || self.pushFresh()
+		}
 	}
 
+	c := make([]prometheus.Collector, 0, len(self.families))
+	for _, f := range self.families {
+		c = append(c, f)
+	}
+
+	c = append(c, self.pushes)
+	c = append(c, self.Exporter.collectors()...)
+	self.Exporter.SetBuildInfo(opts.version, opts.revision)
+
 	for _, col := range c {
 		if err := self.Registry.Register(col); err != nil {
 			log.Errorf("register error: %v", err)
 		}
 	}
+
+	self.handler = promhttp.HandlerFor(self.Registry, promhttp.HandlerOpts{
+		ErrorLog:                            promLogger{log},
+		ErrorHandling:                       promhttp.ContinueOnError,
+		EnableOpenMetrics:                   true,
+		EnableOpenMetricsTextCreatedSamples: true,
+	})
+
 	return &self
 }
 
@@ -116,19 +221,89 @@ type InboundExporter interface {
 	TagString() string
 }
 
+// PushExporter - optional id of panel push, used for exemplars
+type PushExporter interface {
+	PushID() string
+}
+
+func pushID(v any) string {
+	if p, ok := v.(PushExporter); ok {
+		return p.PushID()
+	}
+	return ""
+}
+
+// ExpiryExporter - optional client expiry unix time, 0 if never expires
+type ExpiryExporter interface {
+	ExpiryTimestamp() float64
+}
+
+// EnableExporter - optional client and inbound state, exposed to relabel rules as meta labels
+type EnableExporter interface {
+	ClientEnabled() bool
+	InboundEnabled() bool
+}
+
+// ObservePush - registers traffic push arrival from source
+func (mre *MetricsReg) ObservePush(source string, err error) {
+	mre.Exporter.ObservePush(source, err)
+	mre.pushes.touch(source, err == nil)
+}
+
+// pushFamilies - families built only from panel pushes
+func (mre *MetricsReg) pushFamilies() []*family {
+	f := []*family{
+		mre.ClientTraffic,
+		mre.InboundTraffic,
+	}
+
+	if lm := mre.legacy; lm != nil {
+		f = append(f,
+			lm.ClientUpStat,
+			lm.ClientDownStat,
+			lm.ClientTotalStat,
+			lm.InboundUpStat,
+			lm.InboundDownStat,
+		)
+	}
+	return f
+}
+
+func (mre *MetricsReg) pushFresh() bool {
+	return !mre.pushes.allStale()
+}
+
+// UpdateClient - accumulates client traffic pushed by panel
 func (mre *MetricsReg) UpdateClient(client ClientExporter, tot TotalExporter) {
-	mre.muClient.Lock()
-	setMetric(mre.ClientUpStat, client.UpTraffic(), client.EmailString())
-	setMetric(mre.ClientDownStat, client.DownTraffic(), client.EmailString())
-	setMetric(mre.ClientTotalStat, tot.TotalTraffic(), client.EmailString())
-	mre.muClient.Unlock()
+	if !mre.opts.clientSeries {
+		return
+	}
+
+	lset := mre.clientLabels(client.EmailString())
+	id := pushID(client)
+
+	addPushMetric(mre, mre.ClientTraffic, lset.with(labelDirection, directionUp), client.UpTraffic(), id)
+	addPushMetric(mre, mre.ClientTraffic, lset.with(labelDirection, directionDown), client.DownTraffic(), id)
+
+	if lm := mre.legacy; lm != nil {
+		setMetric(mre, lm.ClientUpStat, lset, client.UpTraffic())
+		setMetric(mre, lm.ClientDownStat, lset, client.DownTraffic())
+		setMetric(mre, lm.ClientTotalStat, lset, tot.TotalTraffic())
+	}
 }
 
+// UpdateInbound - accumulates inbound traffic pushed by panel
 func (mre *MetricsReg) UpdateInbound(inb InboundExporter) {
-	mre.muInbound.Lock()
-	setMetric(mre.InboundUpStat, inb.UpTraffic(), inb.TagString())
-	setMetric(mre.InboundDownStat, inb.DownTraffic(), inb.TagString())
-	mre.muInbound.Unlock()
+	lset := Labels{labelTag: inb.TagString()}
+	id := pushID(inb)
+
+	addPushMetric(mre, mre.InboundTraffic, lset.with(labelDirection, directionUp), inb.UpTraffic(), id)
+	addPushMetric(mre, mre.InboundTraffic, lset.with(labelDirection, directionDown), inb.DownTraffic(), id)
+
+	if lm := mre.legacy; lm != nil {
+		setMetric(mre, lm.InboundUpStat, lset, inb.UpTraffic())
+		setMetric(mre, lm.InboundDownStat, lset, inb.DownTraffic())
+	}
 }
 
 type ProtoExporter interface {
@@ -136,40 +311,193 @@ type ProtoExporter interface {
 	NameString() string
 }
 
-func (mre *MetricsReg) UpdateStats(inb ClientExporter, pr ProtoExporter) {
-	mre.muInboundAll.Lock()
-	setMetric(mre.InboundAllUpStat, inb.UpTraffic(), pr.NameString(), pr.ProtocolString(), inb.EmailString())
-	setMetric(mre.InboundAllDownStat, inb.DownTraffic(), pr.NameString(), pr.ProtocolString(), inb.EmailString())
-	mre.muInboundAll.Unlock()
+// UpdateOutbound - accumulates outbound traffic pushed by panel,
+// ignored once outbound traffic is scraped from panel to not count it twice
+func (mre *MetricsReg) UpdateOutbound(out InboundExporter) {
+	if mre.outboundScraped.Load() {
+		return
+	}
+
+	lset := Labels{labelTag: out.TagString()}
+	id := pushID(out)
+
+	addPushMetric(mre, mre.OutboundTraffic, lset.with(labelDirection, directionUp), out.UpTraffic(), id)
+	addPushMetric(mre, mre.OutboundTraffic, lset.with(labelDirection, directionDown), out.DownTraffic(), id)
 }
 
-func (mre *MetricsReg) Metric() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// UpdateOutboundStats - adds increase of outbound traffic accumulated on panel
+func (mre *MetricsReg) UpdateOutboundStats(out InboundExporter) {
+	mre.outboundScraped.Store(true)
 
-		mre.muClient.RLock()
-		defer mre.muClient.RUnlock()
+	tag := out.TagString()
+	_, upDelta, _ := mre.resets.observe(joinParams("outbound", tag, directionUp), out.UpTraffic())
+	_, downDelta, _ := mre.resets.observe(joinParams("outbound", tag, directionDown), out.DownTraffic())
 
-		mre.muInbound.RLock()
-		defer mre.muInbound.RUnlock()
+	lset := Labels{labelTag: tag}
+	addMetric(mre, mre.OutboundTraffic, lset.with(labelDirection, directionUp), upDelta)
+	addMetric(mre, mre.OutboundTraffic, lset.with(labelDirection, directionDown), downDelta)
+}
 
-		mre.muInboundAll.RLock()
-		defer mre.muInboundAll.RUnlock()
+// StatsExporter - scraped client stats with its inbound
+type StatsExporter interface {
+	ClientExporter
+	ProtoExporter
+}
 
-		mre.log.Debugf("export request from - %s", getReqAddr(r))
+// clientGauges - client state series rebuilt on every scrape
+type clientGauges struct {
+	quota, used, expiry  []sample
+	legacyUp, legacyDown []sample
+}
 
-		reg := mre.Registry
-		if _, err := reg.Gather(); err != nil {
-			mre.log.Errorf("metrics gather error: %v", err)
-		}
+// UpdateStats - sets panel accumulated stats of every scraped client.
+// Panel side resets are detected and carried as offset, so exported values never decrease.
+// Client state gauges are replaced as a whole, so removed clients and cleared expiry disappear
+func (mre *MetricsReg) UpdateStats(stats []StatsExporter) {
+	g := &clientGauges{}
+	for _, st := range stats {
+		mre.updateStats(st, st, g)
+	}
+
+	if !mre.opts.clientSeries {
+		return
+	}
+
+	replaceMetrics(mre, mre.ClientQuota, g.quota)
+	replaceMetrics(mre, mre.ClientUsed, g.used)
+	replaceMetrics(mre, mre.ClientExpiry, g.expiry)
+
+	if lm := mre.legacy; lm != nil {
+		replaceMetrics(mre, lm.InboundAllUpStat, g.legacyUp)
+		replaceMetrics(mre, lm.InboundAllDownStat, g.legacyDown)
+	}
+}
+
+func (mre *MetricsReg) updateStats(inb ClientExporter, pr ProtoExporter, g *clientGauges) {
+	name, proto, email := pr.NameString(), pr.ProtocolString(), inb.EmailString()
+
+	up, upDelta, upReset := mre.resets.observe(joinParams(name, proto, email, directionUp), inb.UpTraffic())
+	down, downDelta, downReset := mre.resets.observe(joinParams(name, proto, email, directionDown), inb.DownTraffic())
+
+	clientLset := mre.clientLabels(email)
+	lset := clientLset.with(labelInbound, name).with(labelProtocol, proto)
 
-		promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	if en, ok := inb.(EnableExporter); ok {
+		clientLset = clientLset.with(metaClientEnable, boolString(en.ClientEnabled()))
+		lset = lset.
+			with(metaClientEnable, boolString(en.ClientEnabled())).
+			with(metaInboundEnable, boolString(en.InboundEnabled()))
+	}
+
+	mre.updateAggregates(lset, email, upDelta, downDelta)
+
+	if upReset || downReset {
+		mre.log.Infof("traffic reset detected: inbound=%s email=%s", name, clientLset[labelEmail])
+	}
+
+	if !mre.opts.clientSeries {
+		return
+	}
+
+	addMetric(mre, mre.InboundClientTraffic, lset.with(labelDirection, directionUp), upDelta)
+	addMetric(mre, mre.InboundClientTraffic, lset.with(labelDirection, directionDown), downDelta)
+
+	if tot, ok := inb.(TotalExporter); ok {
+		g.quota = append(g.quota, sample{lset: clientLset, value: tot.TotalTraffic()})
+	}
+	g.used = append(g.used, sample{lset: clientLset, value: inb.UpTraffic() + inb.DownTraffic()})
+
+	if exp, ok := inb.(ExpiryExporter); ok && exp.ExpiryTimestamp() > 0 {
+		g.expiry = append(g.expiry, sample{lset: clientLset, value: exp.ExpiryTimestamp()})
+	}
+
+	if upReset || downReset {
+		addMetric(mre, mre.ClientResets, clientLset, 1)
+		setMetric(mre, mre.ClientLastReset, clientLset, time.Now().Unix())
+	}
+
+	if mre.legacy != nil {
+		legacyLset := clientLset.with(labelName, name).with(labelProto, proto)
+		g.legacyUp = append(g.legacyUp, sample{lset: legacyLset, value: up})
+		g.legacyDown = append(g.legacyDown, sample{lset: legacyLset, value: down})
+	}
+}
+
+// updateAggregates - adds client traffic increase to per inbound, protocol and group totals.
+// Meta labels are kept, so relabel drop rules exclude clients from aggregates too
+func (mre *MetricsReg) updateAggregates(lset Labels, email string, up, down float64) {
+	aggr := lset.without(labelEmail)
+	proto := aggr.without(labelInbound)
+
+	addMetric(mre, mre.InboundClientsTotal, aggr.with(labelDirection, directionUp), up)
+	addMetric(mre, mre.InboundClientsTotal, aggr.with(labelDirection, directionDown), down)
+
+	addMetric(mre, mre.ProtocolTotal, proto.with(labelDirection, directionUp), up)
+	addMetric(mre, mre.ProtocolTotal, proto.with(labelDirection, directionDown), down)
+
+	if g := mre.opts.groups; g != nil && g.Enabled() {
+		group := proto.without(labelProtocol).with(labelGroup, g.Group(email))
+
+		addMetric(mre, mre.GroupTotal, group.with(labelDirection, directionUp), up)
+		addMetric(mre, mre.GroupTotal, group.with(labelDirection, directionDown), down)
+	}
+}
+
+// clientLabels - client label set with exported identity and raw email meta label
+func (mre *MetricsReg) clientLabels(email string) Labels {
+	return Labels{
+		labelEmail: mre.opts.identity.Identity(email),
+		metaEmail:  email,
+	}
+}
+
+// ClientLabel - exported client label name before relabeling
+const ClientLabel = labelEmail
+
+// ClientProbe - client series label set as relabel rules see it, tools use it to predict exported client label
+func ClientProbe(namespace, email string) map[string]string {
+	return Labels{
+		relabel.MetricNameLabel: nsName(namespace, "client_used_bytes"),
+		labelEmail:              email,
+		metaEmail:               email,
+	}
+}
+
+// relabel - applies relabel rules to series labels, false means series is dropped
+func (mre *MetricsReg) relabel(f *family, lset Labels) (Labels, bool) {
+	lset = lset.with(relabel.MetricNameLabel, f.name)
+	return mre.opts.relabel.Process(lset)
+}
+
+// Publish - makes changes done by Update methods visible for exposition.
+// Must be called after every updates batch
+func (mre *MetricsReg) Publish() {
+	for _, f := range mre.families {
+		f.publish()
+	}
+}
+
+// Metric - exposition handler, reads published snapshots without blocking updates
+func (mre *MetricsReg) Metric() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mre.log.Debugf("export request from - %s", getReqAddr(r))
+		mre.handler.ServeHTTP(w, r)
 	})
 }
 
+// promLogger - promhttp error log adapter
+type promLogger struct {
+	log Logger
+}
+
+func (pl promLogger) Println(v ...interface{}) {
+	pl.log.Errorf("metrics export error: %s", fmt.Sprint(v...))
+}
+
+// getReqAddr - request peer address, client set X-Real-IP is only shown next to it
 func getReqAddr(r *http.Request) string {
-	ip := r.Header.Get("X-Real-IP")
-	if ip != "" {
-		return ip
+	if ip := r.Header.Get("X-Real-IP"); ip != "" {
+		return r.RemoteAddr + " (X-Real-IP " + ip + ")"
 	}
 	return r.RemoteAddr
 }
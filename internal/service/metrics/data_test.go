@@ -0,0 +1,263 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const benchClients = 10000
+
+type nopLogger struct{}
+
+func (nopLogger) Errorf(string, ...interface{}) {}
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Debugf(string, ...interface{}) {}
+
+type testStat struct {
+	email     string
+	inbound   string
+	up, down  float64
+	total     float64
+	expiry    float64
+	clientOn  bool
+	inboundOn bool
+}
+
+func (s *testStat) UpTraffic() float64       { return s.up }
+func (s *testStat) DownTraffic() float64     { return s.down }
+func (s *testStat) EmailString() string      { return s.email }
+func (s *testStat) TotalTraffic() float64    { return s.total }
+func (s *testStat) ExpiryTimestamp() float64 { return s.expiry }
+func (s *testStat) ClientEnabled() bool      { return s.clientOn }
+func (s *testStat) InboundEnabled() bool     { return s.inboundOn }
+func (s *testStat) ProtocolString() string   { return "vless" }
+func (s *testStat) NameString() string       { return s.inbound }
+
+// testPush - client and inbound traffic delta of one panel push
+type testPush struct {
+	email, tag string
+	up, down   float64
+}
+
+func (p *testPush) UpTraffic() float64    { return p.up }
+func (p *testPush) DownTraffic() float64  { return p.down }
+func (p *testPush) EmailString() string   { return p.email }
+func (p *testPush) TotalTraffic() float64 { return p.up + p.down }
+func (p *testPush) TagString() string     { return p.tag }
+
+// testPushes - panel push of every client and its inbound
+func testPushes(stats []*testStat) []*testPush {
+	pushes := make([]*testPush, len(stats))
+	for i, s := range stats {
+		pushes[i] = &testPush{email: s.email, tag: s.inbound, up: 512, down: 2048}
+	}
+	return pushes
+}
+
+func testStats(n int) ([]*testStat, []StatsExporter) {
+	stats := make([]*testStat, n)
+	exporters := make([]StatsExporter, n)
+
+	for i := range stats {
+		stats[i] = &testStat{
+			email:     fmt.Sprintf("client-%05d@vpn.example", i),
+			inbound:   fmt.Sprintf("inbound-%d", i%8),
+			up:        float64(i) * 1024,
+			down:      float64(i) * 4096,
+			total:     100 << 30,
+			expiry:    1.9e9,
+			clientOn:  true,
+			inboundOn: true,
+		}
+		exporters[i] = stats[i]
+	}
+
+	return stats, exporters
+}
+
+// grow - advances every client counters like next panel poll does
+func grow(stats []*testStat) {
+	for _, s := range stats {
+		s.up += 512
+		s.down += 2048
+	}
+}
+
+func gather(t testing.TB, mre *MetricsReg) map[string]*dto.MetricFamily {
+	t.Helper()
+
+	families, err := mre.Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, mf := range families {
+		byName[mf.GetName()] = mf
+	}
+	return byName
+}
+
+func TestUpdateStatsRemovesGoneClients(t *testing.T) {
+	mre := NewMetricsReg(nopLogger{})
+	stats, exporters := testStats(3)
+
+	mre.UpdateStats(exporters)
+	mre.Publish()
+
+	if got := len(gather(t, mre)["xui_client_used_bytes"].GetMetric()); got != 3 {
+		t.Fatalf("client_used_bytes series = %d, want 3", got)
+	}
+
+	stats[0].expiry = 0
+	mre.UpdateStats(exporters[:2])
+	mre.Publish()
+
+	families := gather(t, mre)
+	if got := len(families["xui_client_used_bytes"].GetMetric()); got != 2 {
+		t.Errorf("client_used_bytes series after removal = %d, want 2", got)
+	}
+	if got := len(families["xui_client_quota_bytes"].GetMetric()); got != 2 {
+		t.Errorf("client_quota_bytes series after removal = %d, want 2", got)
+	}
+	if got := len(families["xui_client_expiry_timestamp_seconds"].GetMetric()); got != 1 {
+		t.Errorf("client_expiry_timestamp_seconds series after expiry cleared = %d, want 1", got)
+	}
+}
+
+func TestUpdateStatsCarriesResets(t *testing.T) {
+	mre := NewMetricsReg(nopLogger{})
+	stats, exporters := testStats(1)
+	stats[0].up, stats[0].down = 1000, 3000
+
+	mre.UpdateStats(exporters)
+
+	// panel reset counters, then client sent more traffic
+	stats[0].up, stats[0].down = 100, 200
+	mre.UpdateStats(exporters)
+	mre.Publish()
+
+	families := gather(t, mre)
+
+	total := 0.0
+	for _, m := range families["xui_inbound_client_traffic_bytes_total"].GetMetric() {
+		total += m.GetCounter().GetValue()
+	}
+	if total != 4300 {
+		t.Errorf("client traffic total = %v, want 4300", total)
+	}
+
+	resets := families["xui_client_traffic_resets_total"].GetMetric()
+	if len(resets) != 1 || resets[0].GetCounter().GetValue() != 1 {
+		t.Errorf("client resets = %v, want single series with 1", resets)
+	}
+}
+
+func BenchmarkUpdateStats(b *testing.B) {
+	mre := NewMetricsReg(nopLogger{})
+	stats, exporters := testStats(benchClients)
+
+	mre.UpdateStats(exporters)
+	mre.Publish()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		grow(stats)
+		mre.UpdateStats(exporters)
+		mre.Publish()
+	}
+}
+
+func BenchmarkExposition(b *testing.B) {
+	mre := NewMetricsReg(nopLogger{})
+	_, exporters := testStats(benchClients)
+
+	mre.UpdateStats(exporters)
+	mre.Publish()
+
+	handler := mre.Metric()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metric", nil))
+		io.Copy(io.Discard, rec.Body)
+	}
+}
+
+// BenchmarkExpositionDuringUpdates - scrapes must not wait for concurrent panel scrape and push updates
+func BenchmarkExpositionDuringUpdates(b *testing.B) {
+	mre := NewMetricsReg(nopLogger{})
+	stats, exporters := testStats(benchClients)
+	pushes := testPushes(stats)
+
+	mre.UpdateStats(exporters)
+	for _, p := range pushes {
+		mre.UpdateClient(p, p)
+		mre.UpdateInbound(p)
+	}
+	mre.Publish()
+
+	handler := mre.Metric()
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+
+	// writer - repeats update until benchmark is done
+	writer := func(update func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					update()
+					mre.Publish()
+				}
+			}
+		}()
+	}
+
+	// panel scrape
+	writer(func() {
+		grow(stats)
+		mre.UpdateStats(exporters)
+	})
+
+	// panel pushes, split in two like pushes of two panels
+	for _, part := range [][]*testPush{pushes[:benchClients/2], pushes[benchClients/2:]} {
+		writer(func() {
+			for _, p := range part {
+				mre.UpdateClient(p, p)
+				mre.UpdateInbound(p)
+			}
+		})
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metric", nil))
+		body := rec.Body.String()
+		if !strings.Contains(body, "xui_client_used_bytes") || !strings.Contains(body, "xui_client_traffic_bytes_total") {
+			b.Fatal("client series are missing in exposition")
+		}
+	}
+
+	b.StopTimer()
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+)
+
+// ExporterMetrics - exporter self observability metrics
+type ExporterMetrics struct {
+	BuildInfo *prometheus.GaugeVec
+
+	Pushes   *prometheus.CounterVec
+	LastPush *prometheus.GaugeVec
+
+	Scrapes        *prometheus.CounterVec
+	ScrapeErrors   *prometheus.CounterVec
+	ScrapeDuration prometheus.Histogram
+
+	LoginAttempts *prometheus.CounterVec
+
+	HTTPDuration *prometheus.HistogramVec
+}
+
+func newExporterMetrics(ns string) *ExporterMetrics {
+	return &ExporterMetrics{
+		BuildInfo: newGaugeVec(
+			nsName(ns, "exporter_build_info"),
+			"3X-UI exporter build information",
+			[]string{"version", "revision", "goversion"},
+		),
+
+		Pushes: newCounterVec(
+			nsName(ns, "exporter_pushes_total"),
+			"Traffic pushes received from panel",
+			[]string{"source", "result"},
+		),
+		LastPush: newGaugeVec(
+			nsName(ns, "exporter_last_push_timestamp_seconds"),
+			"Last successful traffic push unix time",
+			[]string{"source"},
+		),
+
+		Scrapes: newCounterVec(
+			nsName(ns, "exporter_scrapes_total"),
+			"Panel scrapes done by exporter",
+			[]string{"result"},
+		),
+		ScrapeErrors: newCounterVec(
+			nsName(ns, "exporter_scrape_errors_total"),
+			"Failed panel scrapes by reason",
+			[]string{"reason"},
+		),
+		ScrapeDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    nsName(ns, "exporter_scrape_duration_seconds"),
+				Help:    "Panel scrape duration",
+				Buckets: prometheus.DefBuckets,
+			},
+		),
+
+		LoginAttempts: newCounterVec(
+			nsName(ns, "exporter_login_attempts_total"),
+			"Panel login attempts",
+			[]string{"result"},
+		),
+
+		HTTPDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    nsName(ns, "exporter_http_request_duration_seconds"),
+				Help:    "Exporter HTTP handlers latency",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"handler", "method", "code"},
+		),
+	}
+}
+
+func (em *ExporterMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		em.BuildInfo,
+		em.Pushes,
+		em.LastPush,
+		em.Scrapes,
+		em.ScrapeErrors,
+		em.ScrapeDuration,
+		em.LoginAttempts,
+		em.HTTPDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	}
+}
+
+// SetBuildInfo - set exporter version and revision
+func (em *ExporterMetrics) SetBuildInfo(version, revision string) {
+	em.BuildInfo.WithLabelValues(version, revision, runtime.Version()).Set(1)
+}
+
+// ObservePush - counts traffic push from source
+func (em *ExporterMetrics) ObservePush(source string, err error) {
+	if err != nil {
+		em.Pushes.WithLabelValues(source, resultError).Inc()
+		return
+	}
+
+	em.Pushes.WithLabelValues(source, resultSuccess).Inc()
+	em.LastPush.WithLabelValues(source).SetToCurrentTime()
+}
+
+// ForgetPushSource - drops series of source which stopped pushing
+func (em *ExporterMetrics) ForgetPushSource(source string) {
+	em.Pushes.DeleteLabelValues(source, resultSuccess)
+	em.Pushes.DeleteLabelValues(source, resultError)
+	em.LastPush.DeleteLabelValues(source)
+}
+
+// ObserveScrape - counts panel scrape, reason is used only for failed ones
+func (em *ExporterMetrics) ObserveScrape(start time.Time, err error, reason string) {
+	em.ScrapeDuration.Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		em.Scrapes.WithLabelValues(resultError).Inc()
+		em.ScrapeErrors.WithLabelValues(reason).Inc()
+		return
+	}
+
+	em.Scrapes.WithLabelValues(resultSuccess).Inc()
+}
+
+// ObserveLogin - counts panel login attempt
+func (em *ExporterMetrics) ObserveLogin(err error) {
+	if err != nil {
+		em.LoginAttempts.WithLabelValues(resultError).Inc()
+		return
+	}
+	em.LoginAttempts.WithLabelValues(resultSuccess).Inc()
+}
+
+// InstrumentHandler - wraps handler with request latency histogram
+func (em *ExporterMetrics) InstrumentHandler(name string, h http.Handler) http.Handler {
+	return promhttp.InstrumentHandlerDuration(
+		em.HTTPDuration.MustCurryWith(prometheus.Labels{"handler": name}),
+		h,
+	)
+}
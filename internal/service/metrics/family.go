@@ -0,0 +1,234 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Labels - series label set before relabeling
+type Labels map[string]string
+
+// with - returns label set copy with added label
+func (l Labels) with(name, value string) Labels {
+	lset := make(Labels, len(l)+1)
+	for k, v := range l {
+		lset[k] = v
+	}
+	lset[name] = value
+	return lset
+}
+
+// without - returns label set copy without listed labels
+func (l Labels) without(names ...string) Labels {
+	lset := make(Labels, len(l))
+	for k, v := range l {
+		lset[k] = v
+	}
+	for _, name := range names {
+		delete(lset, name)
+	}
+	return lset
+}
+
+// series - one exported time series of family
+type series struct {
+	desc    *prometheus.Desc
+	values  []string
+	value   float64
+	created time.Time
+
+	// exemplar - last increment source, nil if unknown
+	exemplar *prometheus.Exemplar
+}
+
+// metric - builds const metric of series, invalid series are reported as collect errors
+func (s *series) metric(kind prometheus.ValueType) prometheus.Metric {
+	var (
+		m   prometheus.Metric
+		err error
+	)
+
+	if kind == prometheus.CounterValue {
+		m, err = prometheus.NewConstMetricWithCreatedTimestamp(s.desc, kind, s.value, s.created, s.values...)
+	} else {
+		m, err = prometheus.NewConstMetric(s.desc, kind, s.value, s.values...)
+	}
+
+	if err != nil {
+		return prometheus.NewInvalidMetric(s.desc, err)
+	}
+
+	if s.exemplar == nil {
+		return m
+	}
+
+	if mex, err := prometheus.NewMetricWithExemplars(m, *s.exemplar); err == nil {
+		return mex
+	}
+	return m
+}
+
+// family - metric family with dynamic label sets.
+// Label names are defined per series, so relabel rules are able to add or remove labels.
+// Writers change series under lock, exposition reads only immutable snapshot made by publish
+type family struct {
+	name string
+	help string
+	kind prometheus.ValueType
+
+	series map[string]*series
+	dirty  bool
+	mu     sync.Mutex
+
+	snapshot atomic.Pointer[[]prometheus.Metric]
+
+	// visible - optional export condition, series are kept but not collected when false
+	visible func() bool
+}
+
+func newGaugeFamily(name, help string) *family {
+	return newFamily(name, help, prometheus.GaugeValue)
+}
+
+func newCounterFamily(name, help string) *family {
+	return newFamily(name, help, prometheus.CounterValue)
+}
+
+func newFamily(name, help string, kind prometheus.ValueType) *family {
+	return &family{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		series: make(map[string]*series),
+	}
+}
+
+// Describe - sends nothing, so family is registered as unchecked collector
+func (f *family) Describe(chan<- *prometheus.Desc) {}
+
+// Collect - sends last published snapshot, never waits for writers
+func (f *family) Collect(ch chan<- prometheus.Metric) {
+	if f.visible != nil && !f.visible() {
+		return
+	}
+
+	snap := f.snapshot.Load()
+	if snap == nil {
+		return
+	}
+
+	for _, m := range *snap {
+		ch <- m
+	}
+}
+
+// publish - builds immutable snapshot of changed family and swaps it for exposition
+func (f *family) publish() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.dirty {
+		return
+	}
+
+	snap := make([]prometheus.Metric, 0, len(f.series))
+	for _, s := range f.series {
+		snap = append(snap, s.metric(f.kind))
+	}
+
+	f.snapshot.Store(&snap)
+	f.dirty = false
+}
+
+func (f *family) set(lset Labels, v float64) {
+	f.mu.Lock()
+	f.lookup(lset).value = v
+	f.dirty = true
+	f.mu.Unlock()
+}
+
+// sample - series value of full family update
+type sample struct {
+	lset  Labels
+	value float64
+}
+
+// replace - swaps every series of family at once, used by families fully rebuilt on each update
+func (f *family) replace(samples []sample) {
+	f.mu.Lock()
+	clear(f.series)
+	for _, s := range samples {
+		f.lookup(s.lset).value = s.value
+	}
+	f.dirty = true
+	f.mu.Unlock()
+}
+
+func (f *family) add(lset Labels, v float64) {
+	f.addExemplar(lset, v, nil)
+}
+
+// addExemplar - adds value and stores exemplar of that increment if it is set
+func (f *family) addExemplar(lset Labels, v float64, ex prometheus.Labels) {
+	if v <= 0 {
+		return
+	}
+
+	f.mu.Lock()
+	s := f.lookup(lset)
+	s.value += v
+	f.dirty = true
+
+	if ex != nil {
+		s.exemplar = &prometheus.Exemplar{
+			Value:     v,
+			Labels:    ex,
+			Timestamp: time.Now(),
+		}
+	}
+	f.mu.Unlock()
+}
+
+// lookup - returns series by label set, creates new one if absent. Must be called under lock
+func (f *family) lookup(lset Labels) *series {
+	names := make([]string, 0, len(lset))
+	for name := range lset {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = lset[name]
+	}
+
+	key := seriesKey(names, values)
+	if s, ok := f.series[key]; ok {
+		return s
+	}
+
+	s := &series{
+		desc:    prometheus.NewDesc(f.name, f.help, names, nil),
+		values:  values,
+		created: time.Now(),
+	}
+	f.series[key] = s
+
+	return s
+}
+
+func seriesKey(names, values []string) string {
+	var b strings.Builder
+	for i := range names {
+		b.WriteString(names[i])
+		b.WriteByte('\xff')
+		b.WriteString(values[i])
+		b.WriteByte('\xff')
+	}
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package metrics
+
+// legacyMetrics - old unprefixed metric set, kept for dashboards migration
+type legacyMetrics struct {
+	ClientUpStat    *family
+	ClientDownStat  *family
+	ClientTotalStat *family
+
+	InboundUpStat   *family
+	InboundDownStat *family
+
+	InboundAllUpStat   *family
+	InboundAllDownStat *family
+}
+
+func newLegacyMetrics() *legacyMetrics {
+	return &legacyMetrics{
+		ClientUpStat: newGaugeFamily(
+			"client_traffic_up",
+			"3X-UI user up stats (deprecated)",
+		),
+		ClientDownStat: newGaugeFamily(
+			"client_traffic_down",
+			"3X-UI user down stats (deprecated)",
+		),
+		ClientTotalStat: newGaugeFamily(
+			"client_traffic_total",
+			"3X-UI user total stats (deprecated)",
+		),
+
+		InboundUpStat: newGaugeFamily(
+			"inbound_traffic_up",
+			"3X-UI inbound up stats (deprecated)",
+		),
+		InboundDownStat: newGaugeFamily(
+			"inbound_traffic_down",
+			"3X-UI inbound down stats (deprecated)",
+		),
+
+		InboundAllUpStat: newGaugeFamily(
+			"inbound_all_up",
+			"3X-UI inbound client up stats (deprecated)",
+		),
+		InboundAllDownStat: newGaugeFamily(
+			"inbound_all_down",
+			"3X-UI inbound client down stats (deprecated)",
+		),
+	}
+}
+
+func (lm *legacyMetrics) families() []*family {
+	return []*family{
+		lm.ClientUpStat,
+		lm.ClientDownStat,
+		lm.ClientTotalStat,
+		lm.InboundUpStat,
+		lm.InboundDownStat,
+		lm.InboundAllUpStat,
+		lm.InboundAllDownStat,
+	}
+}
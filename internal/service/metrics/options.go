@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/eterline/x3ui-exporter/pkg/relabel"
+)
+
+const defaultNamespace = "xui"
+
+type (
+	MetricsOptionFunc func(o *MetricsOptions)
+)
+
+// IdentityMapper - converts client email into exported label value
+type IdentityMapper interface {
+	Identity(email string) string
+}
+
+// GroupMapper - derives client group for aggregated series
+type GroupMapper interface {
+	Enabled() bool
+	Group(email string) string
+}
+
+type rawIdentity struct{}
+
+func (rawIdentity) Identity(email string) string { return email }
+
+type MetricsOptions struct {
+	namespace string
+	legacy    bool
+	identity  IdentityMapper
+	relabel   *relabel.Relabeler
+
+	groups       GroupMapper
+	clientSeries bool
+	clientGeo    bool
+
+	version  string
+	revision string
+
+	staleAfter time.Duration
+	dropStale  bool
+}
+
+// WithNamespace - set metric names namespace prefix
+func WithNamespace(ns string) MetricsOptionFunc {
+	return func(o *MetricsOptions) {
+		if ns != "" {
+			o.namespace = ns
+		}
+	}
+}
+
+// WithLegacyNames - keep exporting old unprefixed metric names
+func WithLegacyNames(v bool) MetricsOptionFunc {
+	return func(o *MetricsOptions) {
+		o.legacy = v
+	}
+}
+
+// WithIdentity - set client identity mapper used for every email label
+func WithIdentity(m IdentityMapper) MetricsOptionFunc {
+	return func(o *MetricsOptions) {
+		if m != nil {
+			o.identity = m
+		}
+	}
+}
+
+// WithRelabel - set relabel rules applied before series are created
+func WithRelabel(r *relabel.Relabeler) MetricsOptionFunc {
+	return func(o *MetricsOptions) {
+		o.relabel = r
+	}
+}
+
+// WithGroups - set client group mapper for per group aggregated series
+func WithGroups(g GroupMapper) MetricsOptionFunc {
+	return func(o *MetricsOptions) {
+		o.groups = g
+	}
+}
+
+// WithClientSeries - enable or disable per client series, aggregates are exported anyway
+func WithClientSeries(v bool) MetricsOptionFunc {
+	return func(o *MetricsOptions) {
+		o.clientSeries = v
+	}
+}
+
+// WithClientGeo - split source country and ASN connections per client.
+// Series count grows as clients times their countries and networks, so it is opt-in
+func WithClientGeo(v bool) MetricsOptionFunc {
+	return func(o *MetricsOptions) {
+		o.clientGeo = v
+	}
+}
+
+// WithBuildInfo - set exporter version for build info metric
+func WithBuildInfo(version, revision string) MetricsOptionFunc {
+	return func(o *MetricsOptions) {
+		o.version = version
+		o.revision = revision
+	}
+}
+
+// WithPushStaleness - set window after which push source is stale.
+// If drop is set, push derived series are not exported while all sources are stale
+func WithPushStaleness(window time.Duration, drop bool) MetricsOptionFunc {
+	return func(o *MetricsOptions) {
+		o.staleAfter = window
+		o.dropStale = drop
+	}
+}
+
+func mustOptions(options ...MetricsOptionFunc) *MetricsOptions {
+
+	o := &MetricsOptions{
+		namespace: defaultNamespace,
+		legacy:    false,
+		identity:  rawIdentity{},
+
+		groups:       nil,
+		clientSeries: true,
+		clientGeo:    false,
+
+		version:  "dev",
+		revision: "",
+
+		staleAfter: 5 * time.Minute,
+		dropStale:  false,
+	}
+
+	for _, option := range options {
+		option(o)
+	}
+
+	return o
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import "sync"
+
+// trafficCounter - keeps state of one accumulated panel counter
+type trafficCounter struct {
+	last   float64
+	offset float64
+}
+
+func (tc *trafficCounter) value() float64 {
+	return tc.offset + tc.last
+}
+
+// resetTracker - detects panel side traffic resets and keeps exported values monotonic.
+// When the panel value decreases, the previous value is carried into the counter offset
+type resetTracker struct {
+	counters map[string]*trafficCounter
+	mu       sync.Mutex
+}
+
+func newResetTracker() *resetTracker {
+	return &resetTracker{
+		counters: make(map[string]*trafficCounter),
+	}
+}
+
+// observe - stores new panel value by key and returns monotonic value,
+// its increase since previous observation and reset flag
+func (rt *resetTracker) observe(key string, v float64) (value, delta float64, reset bool) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	tc, ok := rt.counters[key]
+	if !ok {
+		tc = &trafficCounter{last: v}
+		rt.counters[key] = tc
+		return tc.value(), tc.value(), false
+	}
+
+	prev := tc.value()
+
+	if v < tc.last {
+		tc.offset += tc.last
+		reset = true
+	}
+
+	tc.last = v
+	return tc.value(), tc.value() - prev, reset
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pushForgetAfter - source without pushes for that time is forgotten,
+// so senders that went away do not keep stale series forever
+const pushForgetAfter = 24 * time.Hour
+
+// pushTracker - keeps last push time per source and reports stale sources
+type pushTracker struct {
+	window time.Duration
+	// last - successful push time, active - any push time, source is forgotten by the latter
+	last   map[string]time.Time
+	active map[string]time.Time
+	mu     sync.Mutex
+
+	// forget - called for every forgotten source to drop its other series
+	forget func(source string)
+
+	// seen - any push arrived, all sources being forgotten then means pushes are stale
+	seen bool
+
+	staleDesc *prometheus.Desc
+	ageDesc   *prometheus.Desc
+}
+
+func newPushTracker(ns string, window time.Duration, forget func(source string)) *pushTracker {
+	return &pushTracker{
+		window: window,
+		last:   make(map[string]time.Time),
+		active: make(map[string]time.Time),
+		forget: forget,
+
+		staleDesc: prometheus.NewDesc(
+			nsName(ns, "push_stale"),
+			"Panel traffic pushes did not arrive within staleness window",
+			[]string{"source"}, nil,
+		),
+		ageDesc: prometheus.NewDesc(
+			nsName(ns, "push_age_seconds"),
+			"Seconds since last panel traffic push",
+			[]string{"source"}, nil,
+		),
+	}
+}
+
+// touch - registers push from source, only successful one resets staleness
+func (pt *pushTracker) touch(source string, ok bool) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	now := time.Now()
+	pt.active[source] = now
+	if ok {
+		pt.last[source] = now
+		pt.seen = true
+	}
+	pt.expire()
+}
+
+// expire - drops sources not pushing longer than forget period, must be called under lock
+func (pt *pushTracker) expire() {
+	forget := max(pushForgetAfter, pt.window)
+	for source, active := range pt.active {
+		if time.Since(active) > forget {
+			delete(pt.active, source)
+			delete(pt.last, source)
+			if pt.forget != nil {
+				pt.forget(source)
+			}
+		}
+	}
+}
+
+func (pt *pushTracker) isStale(last time.Time) bool {
+	return pt.window > 0 && time.Since(last) > pt.window
+}
+
+// allStale - true when every known source stopped pushing
+func (pt *pushTracker) allStale() bool {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.expire()
+	if len(pt.last) == 0 {
+		return pt.seen && pt.window > 0
+	}
+
+	for _, last := range pt.last {
+		if !pt.isStale(last) {
+			return false
+		}
+	}
+	return true
+}
+
+func (pt *pushTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pt.staleDesc
+	ch <- pt.ageDesc
+}
+
+func (pt *pushTracker) Collect(ch chan<- prometheus.Metric) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.expire()
+
+	for source, last := range pt.last {
+		stale := 0.0
+		if pt.isStale(last) {
+			stale = 1.0
+		}
+
+		ch <- prometheus.MustNewConstMetric(pt.staleDesc, prometheus.GaugeValue, stale, source)
+		ch <- prometheus.MustNewConstMetric(pt.ageDesc, prometheus.GaugeValue, time.Since(last).Seconds(), source)
+	}
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// sources - push source label values of family
+func sources(t *testing.T, mre *MetricsReg, name string) map[string]bool {
+	t.Helper()
+
+	got := map[string]bool{}
+	for _, m := range gather(t, mre)[name].GetMetric() {
+		for _, lp := range m.GetLabel() {
+			if lp.GetName() == "source" {
+				got[lp.GetValue()] = true
+			}
+		}
+	}
+	return got
+}
+
+func TestPushSourcesForgotten(t *testing.T) {
+	mre := NewMetricsReg(nopLogger{}, WithPushStaleness(time.Minute, false))
+
+	mre.ObservePush("10.0.0.1", nil)
+	mre.ObservePush("10.0.0.2", errors.New("bad body"))
+	mre.ObservePush("10.0.0.3", nil)
+
+	for _, name := range []string{"xui_exporter_pushes_total", "xui_push_age_seconds"} {
+		if got := sources(t, mre, name); !got["10.0.0.1"] || !got["10.0.0.3"] {
+			t.Fatalf("%s sources = %v", name, got)
+		}
+	}
+
+	// first two sources went away a day ago, including one which only failed
+	old := time.Now().Add(-pushForgetAfter - time.Minute)
+	mre.pushes.mu.Lock()
+	for _, source := range []string{"10.0.0.1", "10.0.0.2"} {
+		mre.pushes.active[source] = old
+		if _, ok := mre.pushes.last[source]; ok {
+			mre.pushes.last[source] = old
+		}
+	}
+	mre.pushes.mu.Unlock()
+
+	// sources are forgotten on staleness check, gather may collect push counters before it
+	if !mre.pushFresh() {
+		t.Error("pushes are stale while remaining source pushes")
+	}
+
+	for _, name := range []string{"xui_exporter_pushes_total", "xui_exporter_last_push_timestamp_seconds", "xui_push_age_seconds", "xui_push_stale"} {
+		got := sources(t, mre, name)
+		if len(got) != 1 || !got["10.0.0.3"] {
+			t.Errorf("%s sources after forget = %v, want 10.0.0.3 only", name, got)
+		}
+	}
+}
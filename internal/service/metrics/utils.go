@@ -16,8 +16,43 @@ func joinParams(v ...any) string {
 	return strings.Join(vStr, "/")
 }
 
-func setMetric[T constraints.Integer | constraints.Float](p *prometheus.GaugeVec, value T, params ...string) {
-	p.WithLabelValues(params...).Set(convertNumberToFloat(value))
+func setMetric[T constraints.Integer | constraints.Float](mre *MetricsReg, f *family, lset Labels, value T) {
+	if lset, ok := mre.relabel(f, lset); ok {
+		f.set(lset, convertNumberToFloat(value))
+	}
+}
+
+func addMetric[T constraints.Integer | constraints.Float](mre *MetricsReg, f *family, lset Labels, value T) {
+	if lset, ok := mre.relabel(f, lset); ok {
+		f.add(lset, convertNumberToFloat(value))
+	}
+}
+
+// replaceMetrics - relabels samples and replaces whole family with them
+func replaceMetrics(mre *MetricsReg, f *family, samples []sample) {
+	relabeled := make([]sample, 0, len(samples))
+	for _, s := range samples {
+		if lset, ok := mre.relabel(f, s.lset); ok {
+			relabeled = append(relabeled, sample{lset: lset, value: s.value})
+		}
+	}
+	f.replace(relabeled)
+}
+
+// addPushMetric - adds pushed value with exemplar linking increment to push id
+func addPushMetric[T constraints.Integer | constraints.Float](mre *MetricsReg, f *family, lset Labels, value T, pushID string) {
+	var ex prometheus.Labels
+	if pushID != "" {
+		ex = prometheus.Labels{exemplarPushID: pushID}
+	}
+
+	if lset, ok := mre.relabel(f, lset); ok {
+		f.addExemplar(lset, convertNumberToFloat(value), ex)
+	}
+}
+
+func nsName(namespace, name string) string {
+	return prometheus.BuildFQName(namespace, "", name)
 }
 
 func convertNumberToFloat[T constraints.Integer | constraints.Float](v T) float64 {
@@ -71,6 +106,13 @@ func newCounterVec(name, help string, tags []string) *prometheus.CounterVec {
 	)
 }
 
+func boolString(v bool) string {
+	if v {
+		return "true"
+	}
+	return "false"
+}
+
 func strValueIs(v string, eq ...string) float64 {
 	for _, val := range eq {
 		if v == val {
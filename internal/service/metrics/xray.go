@@ -0,0 +1,73 @@
+package metrics
+
+// xrayMetrics - Xray runtime stats, exported only with Xray stats data source
+type xrayMetrics struct {
+	Goroutines *family
+	GCCount    *family
+	AllocBytes *family
+	SysBytes   *family
+	Objects    *family
+	Uptime     *family
+}
+
+func newXrayMetrics(ns string) *xrayMetrics {
+	return &xrayMetrics{
+		Goroutines: newGaugeFamily(
+			nsName(ns, "xray_goroutines"),
+			"Xray core goroutines count",
+		),
+		GCCount: newCounterFamily(
+			nsName(ns, "xray_gc_total"),
+			"Xray core completed GC cycles",
+		),
+		AllocBytes: newGaugeFamily(
+			nsName(ns, "xray_memory_alloc_bytes"),
+			"Xray core allocated heap bytes",
+		),
+		SysBytes: newGaugeFamily(
+			nsName(ns, "xray_memory_sys_bytes"),
+			"Xray core memory obtained from system",
+		),
+		Objects: newGaugeFamily(
+			nsName(ns, "xray_live_objects"),
+			"Xray core live heap objects",
+		),
+		Uptime: newGaugeFamily(
+			nsName(ns, "xray_uptime_seconds"),
+			"Xray core uptime",
+		),
+	}
+}
+
+func (xm *xrayMetrics) families() []*family {
+	return []*family{
+		xm.Goroutines,
+		xm.GCCount,
+		xm.AllocBytes,
+		xm.SysBytes,
+		xm.Objects,
+		xm.Uptime,
+	}
+}
+
+type XraySysExporter interface {
+	Goroutines() float64
+	GCCount() float64
+	AllocBytes() float64
+	SysBytes() float64
+	Objects() float64
+	UptimeSeconds() float64
+}
+
+// UpdateXraySys - sets Xray runtime stats
+func (mre *MetricsReg) UpdateXraySys(sys XraySysExporter) {
+	xm := mre.xray
+	lset := Labels{}
+
+	setMetric(mre, xm.Goroutines, lset, sys.Goroutines())
+	setMetric(mre, xm.GCCount, lset, sys.GCCount())
+	setMetric(mre, xm.AllocBytes, lset, sys.AllocBytes())
+	setMetric(mre, xm.SysBytes, lset, sys.SysBytes())
+	setMetric(mre, xm.Objects, lset, sys.Objects())
+	setMetric(mre, xm.Uptime, lset, sys.UptimeSeconds())
+}
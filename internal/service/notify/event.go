@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+)
+
+// Event kinds
+const (
+	KindQuota     = "quota"
+	KindExpiresIn = "expires_soon"
+	KindExpired   = "expired"
+	KindDisabled  = "disabled"
+	KindPanelDown = "panel_down"
+	KindPanelUp   = "panel_up"
+	KindAnomaly   = "traffic_anomaly"
+)
+
+// Event - client threshold crossing
+type Event struct {
+	Kind    string    `json:"kind"`
+	Time    time.Time `json:"time"`
+	Panel   string    `json:"panel"`
+	Inbound string    `json:"inbound"`
+	Email   string    `json:"email"`
+
+	// Threshold - crossed quota share for quota events
+	Threshold float64 `json:"threshold,omitempty"`
+	Used      uint64  `json:"used"`
+	Quota     uint64  `json:"quota"`
+
+	// Expiry - client expiry time, zero if never expires
+	Expiry time.Time `json:"expiry,omitzero"`
+
+	// Rate, Baseline - client traffic rate and its usual one in bytes/s for anomaly events
+	Rate     float64 `json:"rate,omitempty"`
+	Baseline float64 `json:"baseline,omitempty"`
+
+	Message string `json:"message"`
+}
+
+// AnomalyEvent - client traffic spike event
+func AnomalyEvent(panel, email string, rate, baseline float64, now time.Time) Event {
+	return Event{
+		Kind:     KindAnomaly,
+		Time:     now,
+		Panel:    panel,
+		Email:    email,
+		Rate:     rate,
+		Baseline: baseline,
+		Message: fmt.Sprintf("client %s traffic spiked to %s/s, baseline %s/s",
+			email, humanBytes(uint64(rate)), humanBytes(uint64(baseline))),
+	}
+}
@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+)
+
+const queueSize = 256
+
+// Notifier - event delivery channel
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, e Event) error
+}
+
+// Dispatcher - delivers events to every notifier in background, slow delivery never blocks scrapes
+type Dispatcher struct {
+	notifiers []Notifier
+	queue     chan Event
+}
+
+func NewDispatcher(notifiers ...Notifier) *Dispatcher {
+	return &Dispatcher{
+		notifiers: notifiers,
+		queue:     make(chan Event, queueSize),
+	}
+}
+
+// Publish - queues events, returns number of events dropped due to full queue
+func (d *Dispatcher) Publish(events ...Event) int {
+	dropped := 0
+	for _, e := range events {
+		select {
+		case d.queue <- e:
+		default:
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// Run - delivers queued events until context is done
+func (d *Dispatcher) Run(ctx context.Context, onErr func(error)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case e := <-d.queue:
+			for _, n := range d.notifiers {
+				if err := n.Notify(ctx, e); err != nil && onErr != nil {
+					onErr(fmt.Errorf("%s: %w", n.Name(), err))
+				}
+			}
+		}
+	}
+}
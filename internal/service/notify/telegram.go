@@ -0,0 +1,181 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultTelegramAPI = "https://api.telegram.org"
+
+	// defaultTelegramTemplate - plain text message
+	defaultTelegramTemplate = `[{{ .Panel }}] {{ .Message }}`
+)
+
+// Telegram - sends events to chats through Bot API
+type Telegram struct {
+	api      string
+	token    string
+	chats    []string
+	tmpl     *template.Template
+	retries  int
+	interval time.Duration
+	client   *http.Client
+
+	// limiter - messages are sent not more often than interval to respect bot limits
+	mu   sync.Mutex
+	next time.Time
+}
+
+type telegramMessage struct {
+	ChatID string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// NewTelegram - creates bot notifier, api is Bot API base url, empty one is official server.
+// Messages are rendered from template file or default plain text one
+func NewTelegram(api, token string, chats []string, templateFile string, interval time.Duration, retries int) (*Telegram, error) {
+	if api == "" {
+		api = defaultTelegramAPI
+	}
+
+	text := defaultTelegramTemplate
+	if templateFile != "" {
+		data, err := os.ReadFile(templateFile)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+
+	tmpl, err := template.New("telegram").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("bad telegram template: %w", err)
+	}
+
+	tg := &Telegram{
+		api:      strings.TrimRight(api, "/"),
+		token:    token,
+		chats:    chats,
+		tmpl:     tmpl,
+		retries:  max(retries, 0),
+		interval: interval,
+		client:   &http.Client{Timeout: webhookTimeout},
+	}
+
+	return tg, nil
+}
+
+func (tg *Telegram) Name() string {
+	return "telegram"
+}
+
+func (tg *Telegram) Notify(ctx context.Context, e Event) error {
+	text := strings.Builder{}
+	if err := tg.tmpl.Execute(&text, e); err != nil {
+		return fmt.Errorf("%w: template: %w", ErrDelivery, err)
+	}
+
+	var errs []error
+	for _, chat := range tg.chats {
+		msg := telegramMessage{ChatID: chat, Text: text.String()}
+
+		err := retry(ctx, tg.retries, func() (bool, error) {
+			return tg.send(ctx, msg)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("chat %s: %w", chat, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// wait - blocks until next message slot
+func (tg *Telegram) wait(ctx context.Context) error {
+	tg.mu.Lock()
+	now := time.Now()
+	slot := now
+	if tg.next.After(now) {
+		slot = tg.next
+	}
+	tg.next = slot.Add(tg.interval)
+	tg.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(slot.Sub(now)):
+		return nil
+	}
+}
+
+// delay - postpones next message slot on flood control answer
+func (tg *Telegram) delay(d time.Duration) {
+	tg.mu.Lock()
+	if until := time.Now().Add(d); until.After(tg.next) {
+		tg.next = until
+	}
+	tg.mu.Unlock()
+}
+
+func (tg *Telegram) send(ctx context.Context, msg telegramMessage) (bool, error) {
+	if err := tg.wait(ctx); err != nil {
+		return false, err
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		tg.api+"/bot"+tg.token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return false, errors.New("bad telegram api url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := tg.client.Do(req)
+	if err != nil {
+		// url error contains bot token
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	res := telegramResponse{}
+	json.NewDecoder(resp.Body).Decode(&res)
+
+	if resp.StatusCode == http.StatusTooManyRequests && res.Parameters.RetryAfter > 0 {
+		tg.delay(time.Duration(res.Parameters.RetryAfter) * time.Second)
+	}
+
+	temporary, err := statusResult(resp.StatusCode)
+	if err != nil && res.Description != "" {
+		err = fmt.Errorf("%w: %s", err, res.Description)
+	}
+
+	return temporary, err
+}
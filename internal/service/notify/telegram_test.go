@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testBotToken = "123456:s3cr3t-bot-token"
+
+// fakeBotAPI - Bot API stand-in recording sent messages, reply decides answer of every request
+type fakeBotAPI struct {
+	mu       sync.Mutex
+	messages []telegramMessage
+	times    []time.Time
+	reply    func(n int, msg telegramMessage) (int, string)
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/bot"+testBotToken+"/sendMessage" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"ok":false,"description":"Not Found"}`))
+		return
+	}
+
+	msg := telegramMessage{}
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	n := len(f.messages)
+	f.messages = append(f.messages, msg)
+	f.times = append(f.times, time.Now())
+	f.mu.Unlock()
+
+	code, body := http.StatusOK, `{"ok":true}`
+	if f.reply != nil {
+		code, body = f.reply(n, msg)
+	}
+	w.WriteHeader(code)
+	w.Write([]byte(body))
+}
+
+func serveBot(t *testing.T, f *fakeBotAPI) string {
+	t.Helper()
+
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	return srv.URL + "/"
+}
+
+func TestTelegramNotify(t *testing.T) {
+	f := &fakeBotAPI{}
+	tg, err := NewTelegram(serveBot(t, f), testBotToken, []string{"100", "-200"}, "", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tg.Notify(context.Background(), Event{Panel: "node-1", Message: "client alice expired"}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	want := []telegramMessage{
+		{ChatID: "100", Text: "[node-1] client alice expired"},
+		{ChatID: "-200", Text: "[node-1] client alice expired"},
+	}
+	if len(f.messages) != len(want) || f.messages[0] != want[0] || f.messages[1] != want[1] {
+		t.Errorf("sent messages = %+v, want %+v", f.messages, want)
+	}
+}
+
+func TestTelegramErrors(t *testing.T) {
+	f := &fakeBotAPI{reply: func(_ int, msg telegramMessage) (int, string) {
+		switch msg.ChatID {
+		case "blocked":
+			return http.StatusForbidden, `{"ok":false,"description":"Forbidden: bot was blocked by the user"}`
+		default:
+			return http.StatusBadGateway, `{"ok":false}`
+		}
+	}}
+	tg, err := NewTelegram(serveBot(t, f), testBotToken, []string{"blocked", "flaky"}, "", 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tg.Notify(context.Background(), Event{Message: "m"})
+	if !errors.Is(err, ErrDelivery) {
+		t.Fatalf("Notify() error = %v, want %v", err, ErrDelivery)
+	}
+	if !strings.Contains(err.Error(), "bot was blocked") {
+		t.Errorf("Notify() error = %v, want Bot API description", err)
+	}
+
+	// permanent failure is sent once, temporary one is retried
+	if len(f.messages) != 4 {
+		t.Errorf("requests = %d, want 1 to blocked chat and 3 to flaky one", len(f.messages))
+	}
+}
+
+func TestTelegramFloodControl(t *testing.T) {
+	f := &fakeBotAPI{reply: func(n int, _ telegramMessage) (int, string) {
+		if n == 0 {
+			return http.StatusTooManyRequests, `{"ok":false,"description":"Too Many Requests","parameters":{"retry_after":1}}`
+		}
+		return http.StatusOK, `{"ok":true}`
+	}}
+	tg, err := NewTelegram(serveBot(t, f), testBotToken, []string{"100"}, "", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tg.Notify(context.Background(), Event{Message: "m"}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if len(f.times) != 2 {
+		t.Fatalf("requests = %d, want 2", len(f.times))
+	}
+	if gap := f.times[1].Sub(f.times[0]); gap < time.Second {
+		t.Errorf("retry after flood control in %v, want at least retry_after 1s", gap)
+	}
+}
+
+func TestTelegramInterval(t *testing.T) {
+	const interval = 30 * time.Millisecond
+
+	f := &fakeBotAPI{}
+	tg, err := NewTelegram(serveBot(t, f), testBotToken, []string{"1", "2", "3"}, "", interval, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tg.Notify(context.Background(), Event{Message: "m"}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	for i := 1; i < len(f.times); i++ {
+		if gap := f.times[i].Sub(f.times[i-1]); gap < interval-5*time.Millisecond {
+			t.Errorf("message %d sent %v after previous, want at least %v", i, gap, interval)
+		}
+	}
+}
+
+func TestTelegramHidesToken(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	api := srv.URL
+	srv.Close()
+
+	tg, err := NewTelegram(api, testBotToken, []string{"100"}, "", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tg.Notify(context.Background(), Event{Message: "m"})
+	if err == nil {
+		t.Fatal("Notify() to closed server succeeded")
+	}
+	if strings.Contains(err.Error(), "s3cr3t") || strings.Contains(tg.Name(), "s3cr3t") {
+		t.Errorf("Notify() error leaks bot token: %v", err)
+	}
+}
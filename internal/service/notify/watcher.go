@@ -0,0 +1,198 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/eterline/x3ui-exporter/internal/service/scrape"
+)
+
+// MarkStore - persistent storage of reported conditions
+type MarkStore interface {
+	NotifyMarks(ctx context.Context, panel string) ([]string, error)
+	SaveNotifyMarks(ctx context.Context, panel string, keys []string) error
+}
+
+// Watcher - turns scraped client stats into threshold events.
+// Every condition fires once and fires again only after it stopped to hold,
+// like quota usage after traffic reset or expiry after subscription renewal
+type Watcher struct {
+	panel      string
+	thresholds []float64
+	expiryWarn time.Duration
+
+	mu    sync.Mutex
+	fired map[string]struct{}
+	dirty bool
+	down  bool
+	store MarkStore
+}
+
+// NewWatcher - creates watcher of quota share thresholds and expiry warning lead time, 0 disables warning
+func NewWatcher(panel string, thresholds []float64, expiryWarn time.Duration) *Watcher {
+	thresholds = slices.Clone(thresholds)
+	slices.Sort(thresholds)
+
+	return &Watcher{
+		panel:      panel,
+		thresholds: thresholds,
+		expiryWarn: expiryWarn,
+		fired:      map[string]struct{}{},
+	}
+}
+
+// Restore - loads conditions reported before restart from store and saves further changes there
+func (w *Watcher) Restore(ctx context.Context, store MarkStore) error {
+	keys, err := store.NotifyMarks(ctx, w.panel)
+	if err != nil {
+		return fmt.Errorf("failed to load notify marks: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, key := range keys {
+		w.fired[key] = struct{}{}
+	}
+	w.store = store
+
+	return nil
+}
+
+// Sync - saves reported conditions to store if they changed since previous sync
+func (w *Watcher) Sync(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.store == nil || !w.dirty {
+		return nil
+	}
+
+	keys := slices.Sorted(maps.Keys(w.fired))
+	if err := w.store.SaveNotifyMarks(ctx, w.panel, keys); err != nil {
+		return err
+	}
+	w.dirty = false
+
+	return nil
+}
+
+// Evaluate - returns events of conditions met first time
+func (w *Watcher) Evaluate(stats []scrape.ClientStat, now time.Time) []Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	holding := make(map[string]struct{}, len(w.fired))
+	events := []Event{}
+
+	fire := func(key string, e Event) {
+		holding[key] = struct{}{}
+		if _, ok := w.fired[key]; ok {
+			return
+		}
+		events = append(events, e)
+	}
+
+	for _, st := range stats {
+		base := Event{
+			Time:    now,
+			Panel:   w.panel,
+			Inbound: st.Name,
+			Email:   st.Email,
+			Used:    st.Up + st.Down,
+			Quota:   st.Total,
+		}
+
+		// negative expiry is duration after first use, not started yet
+		if st.ExpiryTime > 0 {
+			base.Expiry = time.UnixMilli(st.ExpiryTime)
+		}
+
+		key := st.Name + "\xff" + st.Email + "\xff"
+
+		if st.Total > 0 {
+			share := float64(base.Used) / float64(st.Total)
+
+			// every crossed threshold is marked, only the highest one is reported
+			highest := -1
+			for i, t := range w.thresholds {
+				if share < t {
+					break
+				}
+				highest = i
+				holding[fmt.Sprintf("%s%s/%g", key, KindQuota, t)] = struct{}{}
+			}
+
+			if highest >= 0 {
+				t := w.thresholds[highest]
+
+				e := base
+				e.Kind = KindQuota
+				e.Threshold = t
+				e.Message = fmt.Sprintf("client %s used %.0f%% of traffic quota", st.Email, share*100)
+				fire(fmt.Sprintf("%s%s/%g", key, KindQuota, t), e)
+			}
+		}
+
+		if !base.Expiry.IsZero() {
+			left := base.Expiry.Sub(now)
+
+			switch {
+			case left <= 0:
+				e := base
+				e.Kind = KindExpired
+				e.Message = fmt.Sprintf("client %s expired", st.Email)
+				fire(key+KindExpired, e)
+
+			case w.expiryWarn > 0 && left <= w.expiryWarn:
+				e := base
+				e.Kind = KindExpiresIn
+				e.Message = fmt.Sprintf("client %s expires in %s", st.Email, left.Truncate(time.Minute))
+				fire(key+KindExpiresIn, e)
+			}
+		}
+
+		if !st.Enable {
+			e := base
+			e.Kind = KindDisabled
+			e.Message = fmt.Sprintf("client %s disabled", st.Email)
+			fire(key+KindDisabled, e)
+		}
+	}
+
+	if !maps.Equal(w.fired, holding) {
+		w.dirty = true
+	}
+	w.fired = holding
+
+	return events
+}
+
+// ObserveScrape - returns panel down event on first failed scrape and panel up one on recovery
+func (w *Watcher) ObserveScrape(err error, now time.Time) []Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	e := Event{Time: now, Panel: w.panel}
+
+	switch {
+	case err != nil && !w.down:
+		w.down = true
+		e.Kind = KindPanelDown
+		e.Message = fmt.Sprintf("panel %s is unreachable: %v", w.panel, err)
+
+	case err == nil && w.down:
+		w.down = false
+		e.Kind = KindPanelUp
+		e.Message = fmt.Sprintf("panel %s is reachable again", w.panel)
+
+	default:
+		return nil
+	}
+
+	return []Event{e}
+}
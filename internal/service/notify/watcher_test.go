@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/eterline/x3ui-exporter/internal/service/scrape"
+)
+
+type memMarks map[string][]string
+
+func (m memMarks) NotifyMarks(_ context.Context, panel string) ([]string, error) {
+	return m[panel], nil
+}
+
+func (m memMarks) SaveNotifyMarks(_ context.Context, panel string, keys []string) error {
+	m[panel] = slices.Clone(keys)
+	return nil
+}
+
+func kinds(events []Event) []string {
+	ks := make([]string, 0, len(events))
+	for _, e := range events {
+		ks = append(ks, e.Kind)
+	}
+	slices.Sort(ks)
+	return ks
+}
+
+func TestWatcherEvaluate(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	w := NewWatcher("p", []float64{1, 0.8}, 72*time.Hour)
+
+	alice := scrape.ClientStat{
+		Name: "vless", Email: "alice", Enable: true,
+		Up: 40, Down: 45, Total: 100,
+		ExpiryTime: now.Add(48 * time.Hour).UnixMilli(),
+	}
+
+	events := w.Evaluate([]scrape.ClientStat{alice}, now)
+	if got := kinds(events); !slices.Equal(got, []string{KindExpiresIn, KindQuota}) {
+		t.Fatalf("Evaluate() kinds = %v", got)
+	}
+	for _, e := range events {
+		if e.Kind == KindQuota && e.Threshold != 0.8 {
+			t.Errorf("quota event threshold = %v, want 0.8", e.Threshold)
+		}
+	}
+
+	if events := w.Evaluate([]scrape.ClientStat{alice}, now); len(events) != 0 {
+		t.Errorf("Evaluate() repeated events = %+v", events)
+	}
+
+	// only the newly crossed threshold fires
+	alice.Down = 60
+	events = w.Evaluate([]scrape.ClientStat{alice}, now)
+	if len(events) != 1 || events[0].Kind != KindQuota || events[0].Threshold != 1 {
+		t.Errorf("Evaluate() after full quota = %+v", events)
+	}
+
+	// traffic reset clears condition, next crossing fires again
+	alice.Up, alice.Down = 0, 0
+	w.Evaluate([]scrape.ClientStat{alice}, now)
+	alice.Up, alice.Down = 50, 35
+	events = w.Evaluate([]scrape.ClientStat{alice}, now)
+	if len(events) != 1 || events[0].Kind != KindQuota || events[0].Threshold != 0.8 {
+		t.Errorf("Evaluate() after reset = %+v", events)
+	}
+}
+
+func TestWatcherRestore(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	store := memMarks{}
+
+	stats := []scrape.ClientStat{
+		{Name: "vless", Email: "alice", Enable: false},
+		{Name: "vless", Email: "bob", Enable: true, ExpiryTime: now.Add(-time.Hour).UnixMilli()},
+	}
+
+	w := NewWatcher("p", nil, 0)
+	if err := w.Restore(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	if got := kinds(w.Evaluate(stats, now)); !slices.Equal(got, []string{KindDisabled, KindExpired}) {
+		t.Fatalf("Evaluate() kinds = %v", got)
+	}
+	if err := w.Sync(ctx); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if len(store["p"]) != 2 {
+		t.Fatalf("saved marks = %v, want 2", store["p"])
+	}
+
+	// restarted watcher remembers reported conditions
+	restarted := NewWatcher("p", nil, 0)
+	if err := restarted.Restore(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	if events := restarted.Evaluate(stats, now); len(events) != 0 {
+		t.Errorf("Evaluate() after restart = %+v, want none", events)
+	}
+
+	// renewed client is forgotten and fires again once expired after restart
+	restarted.Evaluate(stats[:1], now)
+	if err := restarted.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	again := NewWatcher("p", nil, 0)
+	if err := again.Restore(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	if got := kinds(again.Evaluate(stats, now)); !slices.Equal(got, []string{KindExpired}) {
+		t.Errorf("Evaluate() after renewal = %v, want expired only", got)
+	}
+}
+
+func TestWatcherObserveScrape(t *testing.T) {
+	w := NewWatcher("p", nil, 0)
+	now := time.Now()
+
+	if events := w.ObserveScrape(nil, now); len(events) != 0 {
+		t.Errorf("ObserveScrape() healthy = %+v", events)
+	}
+	if got := kinds(w.ObserveScrape(context.DeadlineExceeded, now)); !slices.Equal(got, []string{KindPanelDown}) {
+		t.Errorf("ObserveScrape() failure = %v", got)
+	}
+	if events := w.ObserveScrape(context.DeadlineExceeded, now); len(events) != 0 {
+		t.Errorf("ObserveScrape() repeated failure = %+v", events)
+	}
+	if got := kinds(w.ObserveScrape(nil, now)); !slices.Equal(got, []string{KindPanelUp}) {
+		t.Errorf("ObserveScrape() recovery = %v", got)
+	}
+}
@@ -0,0 +1,166 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"text/template"
+	"time"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// retryBackoff - delay before first redelivery, doubled on every next one
+var retryBackoff = time.Second
+
+var (
+	ErrDelivery = errors.New("event delivery failed")
+)
+
+// defaultWebhookTemplate - whole event as JSON object
+const defaultWebhookTemplate = `{{ json . }}`
+
+// templateFuncs - helpers of webhook body templates
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"bytes": humanBytes,
+}
+
+// Webhook - posts events as templated JSON body
+type Webhook struct {
+	url     string
+	host    string
+	tmpl    *template.Template
+	retries int
+	client  *http.Client
+}
+
+// NewWebhook - creates webhook with body template file, empty file sends event as JSON.
+// Failed delivery is retried with exponential backoff
+func NewWebhook(rawURL, templateFile string, retries int) (*Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, errors.New("bad webhook url")
+	}
+
+	text := defaultWebhookTemplate
+	if templateFile != "" {
+		data, err := os.ReadFile(templateFile)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+
+	tmpl, err := template.New("webhook").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("bad webhook template: %w", err)
+	}
+
+	wh := &Webhook{
+		url:     rawURL,
+		host:    u.Scheme + "://" + u.Host,
+		tmpl:    tmpl,
+		retries: max(retries, 0),
+		client:  &http.Client{Timeout: webhookTimeout},
+	}
+
+	return wh, nil
+}
+
+// Name - webhook origin only, path and query often carry tokens
+func (wh *Webhook) Name() string {
+	return "webhook " + wh.host
+}
+
+func (wh *Webhook) Notify(ctx context.Context, e Event) error {
+	body := bytes.Buffer{}
+	if err := wh.tmpl.Execute(&body, e); err != nil {
+		return fmt.Errorf("%w: template: %w", ErrDelivery, err)
+	}
+
+	return retry(ctx, wh.retries, func() (bool, error) {
+		return wh.post(ctx, body.Bytes())
+	})
+}
+
+// post - sends body once, returns true if failure is temporary and worth retry
+func (wh *Webhook) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.New("bad webhook url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		// url error contains webhook url
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return statusResult(resp.StatusCode)
+}
+
+// statusResult - 5xx and 429 responses are temporary failures
+func statusResult(code int) (bool, error) {
+	switch {
+	case code >= 200 && code < 300:
+		return false, nil
+	case code == http.StatusTooManyRequests || code >= 500:
+		return true, fmt.Errorf("status %d", code)
+	default:
+		return false, fmt.Errorf("status %d", code)
+	}
+}
+
+// retry - calls send until success, permanent failure or retries exhaustion
+func retry(ctx context.Context, retries int, send func() (bool, error)) error {
+	backoff := retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		temporary, err := send()
+		if err == nil {
+			return nil
+		}
+
+		if !temporary || attempt >= retries {
+			return fmt.Errorf("%w after %d attempts: %w", ErrDelivery, attempt+1, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrDelivery, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func humanBytes(v uint64) string {
+	const unit = 1024
+	if v < unit {
+		return fmt.Sprintf("%d B", v)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := v / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(v)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,172 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func init() {
+	retryBackoff = time.Millisecond
+}
+
+// statusServer - answers with given statuses in order, last one repeats
+func statusServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	calls := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1)) - 1
+		w.WriteHeader(statuses[min(n, len(statuses)-1)])
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, calls
+}
+
+func TestWebhookBody(t *testing.T) {
+	got := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		e := Event{}
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Errorf("body is not event JSON: %v", err)
+		}
+		got <- e
+	}))
+	defer srv.Close()
+
+	wh, err := NewWebhook(srv.URL, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := Event{Kind: KindQuota, Panel: "p", Email: "alice", Threshold: 0.8, Used: 80, Quota: 100}
+	if err := wh.Notify(context.Background(), e); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if r := <-got; r.Kind != KindQuota || r.Email != "alice" || r.Threshold != 0.8 || r.Used != 80 {
+		t.Errorf("delivered event = %+v", r)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	got := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got <- string(b)
+	}))
+	defer srv.Close()
+
+	file := filepath.Join(t.TempDir(), "body.tmpl")
+	tmpl := `{"text": {{ json .Message }}, "used": "{{ bytes .Used }}"}`
+	if err := os.WriteFile(file, []byte(tmpl), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	wh, err := NewWebhook(srv.URL, file, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wh.Notify(context.Background(), Event{Message: `client "a" expired`, Used: 3 << 20}); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if body, want := <-got, `{"text": "client \"a\" expired", "used": "3.0 MiB"}`; body != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		calls    int32
+		ok       bool
+	}{
+		{"temporary failures then success", []int{502, 429, 200}, 3, 3, true},
+		{"retries exhausted", []int{503}, 2, 3, false},
+		{"permanent failure is not retried", []int{400}, 3, 1, false},
+		{"no retries", []int{500, 200}, 0, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := statusServer(t, tt.statuses...)
+
+			wh, err := NewWebhook(srv.URL, "", tt.retries)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = wh.Notify(context.Background(), Event{Kind: KindExpired})
+			if (err == nil) != tt.ok {
+				t.Errorf("Notify() error = %v, want success %v", err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, ErrDelivery) {
+				t.Errorf("Notify() error = %v, want %v", err, ErrDelivery)
+			}
+			if got := calls.Load(); got != tt.calls {
+				t.Errorf("delivery attempts = %d, want %d", got, tt.calls)
+			}
+		})
+	}
+}
+
+func TestWebhookRetryCanceled(t *testing.T) {
+	srv, calls := statusServer(t, 503)
+
+	wh, err := NewWebhook(srv.URL, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := wh.Notify(ctx, Event{}); !errors.Is(err, ErrDelivery) {
+		t.Errorf("Notify() error = %v, want %v", err, ErrDelivery)
+	}
+	if got := calls.Load(); got > 1 {
+		t.Errorf("delivery attempts after cancel = %d, want at most 1", got)
+	}
+}
+
+func TestWebhookHidesURL(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	secret := srv.URL + "/hooks/s3cr3t-token?key=s3cr3t-key"
+	srv.Close()
+
+	wh, err := NewWebhook(secret, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := wh.Name(); strings.Contains(name, "s3cr3t") || !strings.Contains(name, srv.Listener.Addr().String()) {
+		t.Errorf("Name() = %q, want origin only", name)
+	}
+
+	err = wh.Notify(context.Background(), Event{})
+	if err == nil {
+		t.Fatal("Notify() to closed server succeeded")
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("Notify() error leaks webhook url: %v", err)
+	}
+
+	if _, err := NewWebhook("hooks/relative", "", 0); err == nil {
+		t.Error("NewWebhook() accepted url without host")
+	}
+}
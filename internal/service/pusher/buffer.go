@@ -0,0 +1,116 @@
+package pusher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const bufferExt = ".rw"
+
+// buffer - write-ahead queue of remote write requests, one file per request.
+// Request is written before send and removed after delivery, so it survives outages and restarts
+type buffer struct {
+	dir  string
+	size int64
+
+	mu  sync.Mutex
+	seq int
+}
+
+func newBuffer(dir string, size int64) (*buffer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create push buffer: %w", err)
+	}
+	return &buffer{dir: dir, size: size}, nil
+}
+
+// append - stores request, oldest ones are dropped to fit size limit, returns dropped count
+func (b *buffer) append(req []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// file names sort in write order
+	b.seq++
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), b.seq%1e6, bufferExt)
+
+	tmp := filepath.Join(b.dir, name+".tmp")
+	if err := os.WriteFile(tmp, req, 0o640); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, filepath.Join(b.dir, name)); err != nil {
+		return 0, err
+	}
+
+	return b.trim()
+}
+
+// trim - removes oldest requests above size limit, newest one is always kept
+func (b *buffer) trim() (int, error) {
+	files, sizes, err := b.list()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, s := range sizes {
+		total += s
+	}
+
+	dropped := 0
+	for i := 0; i < len(files)-1 && total > b.size; i++ {
+		if err := os.Remove(files[i]); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return dropped, err
+		}
+		total -= sizes[i]
+		dropped++
+	}
+
+	return dropped, nil
+}
+
+// pending - stored request files, oldest first
+func (b *buffer) pending() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	files, _, err := b.list()
+	return files, err
+}
+
+func (b *buffer) list() ([]string, []int64, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := []string{}
+	sizes := []int64{}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), bufferExt) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, filepath.Join(b.dir, e.Name()))
+		sizes = append(sizes, info.Size())
+	}
+
+	// ReadDir sorts entries by name, so oldest requests come first
+	return files, sizes, nil
+}
+
+func (b *buffer) remove(file string) error {
+	if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
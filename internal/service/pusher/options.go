@@ -0,0 +1,95 @@
+package pusher
+
+import (
+	"time"
+)
+
+const (
+	defaultJob        = "3xui-exporter"
+	defaultInterval   = 30 * time.Second
+	defaultBufferSize = 64 << 20
+)
+
+type (
+	OptionFunc func(o *Options)
+)
+
+type Options struct {
+	job      string
+	instance string
+	interval time.Duration
+	retries  int
+
+	username string
+	password string
+	bearer   string
+
+	bufferDir  string
+	bufferSize int64
+}
+
+// WithJob - job and instance labels of pushed series
+func WithJob(job, instance string) OptionFunc {
+	return func(o *Options) {
+		if job != "" {
+			o.job = job
+		}
+		o.instance = instance
+	}
+}
+
+// WithInterval - registry push interval
+func WithInterval(d time.Duration) OptionFunc {
+	return func(o *Options) {
+		if d > 0 {
+			o.interval = d
+		}
+	}
+}
+
+// WithRetries - retries of failed push with exponential backoff
+func WithRetries(n int) OptionFunc {
+	return func(o *Options) {
+		o.retries = max(n, 0)
+	}
+}
+
+// WithBasicAuth - push requests basic authorization, empty username disables it
+func WithBasicAuth(username, password string) OptionFunc {
+	return func(o *Options) {
+		o.username = username
+		o.password = password
+	}
+}
+
+// WithBearerToken - push requests bearer authorization, used instead of basic one
+func WithBearerToken(token string) OptionFunc {
+	return func(o *Options) {
+		o.bearer = token
+	}
+}
+
+// WithBuffer - directory of write-ahead buffer keeping remote write requests during outages,
+// oldest requests are dropped above size bytes
+func WithBuffer(dir string, size int64) OptionFunc {
+	return func(o *Options) {
+		o.bufferDir = dir
+		if size > 0 {
+			o.bufferSize = size
+		}
+	}
+}
+
+func mustOptions(options ...OptionFunc) *Options {
+	opts := &Options{
+		job:        defaultJob,
+		interval:   defaultInterval,
+		bufferSize: defaultBufferSize,
+	}
+
+	for _, fn := range options {
+		fn(opts)
+	}
+
+	return opts
+}
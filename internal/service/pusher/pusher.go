@@ -0,0 +1,276 @@
+package pusher
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Push modes
+const (
+	ModePushgateway = "pushgateway"
+	ModeRemoteWrite = "remote_write"
+)
+
+const pushTimeout = 30 * time.Second
+
+// retryBackoff - delay before first retry, doubled on every next one
+var retryBackoff = time.Second
+
+var (
+	ErrPush        = errors.New("metrics push failed")
+	ErrUnknownMode = errors.New("unknown push mode")
+)
+
+// Pusher - periodically sends registry to Pushgateway or remote write endpoint,
+// for exporters behind NAT which can not be scraped
+type Pusher struct {
+	mode     string
+	url      string
+	gatherer prometheus.Gatherer
+	opts     *Options
+	client   *http.Client
+
+	// buf - remote write requests waiting for delivery, nil if buffer is disabled
+	buf *buffer
+}
+
+// NewPusher - creates pusher of gatherer metrics to url in pushgateway or remote_write mode
+func NewPusher(mode, target string, g prometheus.Gatherer, options ...OptionFunc) (*Pusher, error) {
+	opts := mustOptions(options...)
+
+	u, err := url.ParseRequestURI(target)
+	if err != nil {
+		return nil, fmt.Errorf("bad push url: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("bad push url: %s has no host", target)
+	}
+
+	p := &Pusher{
+		mode:     mode,
+		url:      strings.TrimRight(target, "/"),
+		gatherer: g,
+		opts:     opts,
+		client:   &http.Client{Timeout: pushTimeout},
+	}
+
+	switch mode {
+	case ModePushgateway:
+		p.url += groupingPath(opts.job, opts.instance)
+
+	case ModeRemoteWrite:
+		if opts.bufferDir != "" {
+			buf, err := newBuffer(opts.bufferDir, opts.bufferSize)
+			if err != nil {
+				return nil, err
+			}
+			p.buf = buf
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMode, mode)
+	}
+
+	return p, nil
+}
+
+// Run - pushes metrics every interval until context is done
+func (p *Pusher) Run(ctx context.Context, onErr func(error)) {
+	ticker := time.NewTicker(p.opts.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if err := p.Push(ctx); err != nil {
+				onErr(err)
+			}
+		}
+	}
+}
+
+// Push - gathers and sends metrics once
+func (p *Pusher) Push(ctx context.Context) error {
+	families, err := p.gatherer.Gather()
+	if err != nil && len(families) == 0 {
+		return fmt.Errorf("%w: gather: %w", ErrPush, err)
+	}
+
+	if p.mode == ModePushgateway {
+		body := bytes.Buffer{}
+		format := expfmt.NewFormat(expfmt.TypeTextPlain)
+
+		enc := expfmt.NewEncoder(&body, format)
+		for _, mf := range families {
+			if err := enc.Encode(mf); err != nil {
+				return fmt.Errorf("%w: encode: %w", ErrPush, err)
+			}
+		}
+
+		_, err := p.retry(ctx, func() (bool, error) {
+			return p.send(ctx, http.MethodPut, body.Bytes(), http.Header{
+				"Content-Type": {string(format)},
+			})
+		})
+		return err
+	}
+
+	extra := []label{{"job", p.opts.job}}
+	if p.opts.instance != "" {
+		extra = append(extra, label{"instance", p.opts.instance})
+	}
+	req := writeRequest(families, extra, time.Now().UnixMilli())
+
+	if p.buf == nil {
+		_, err := p.retry(ctx, func() (bool, error) {
+			return p.sendWrite(ctx, req)
+		})
+		return err
+	}
+
+	dropped, err := p.buf.append(req)
+	if err != nil {
+		return fmt.Errorf("%w: buffer: %w", ErrPush, err)
+	}
+
+	errs := []error{}
+	if dropped > 0 {
+		errs = append(errs, fmt.Errorf("%w: buffer is full, %d oldest requests dropped", ErrPush, dropped))
+	}
+	if err := p.drain(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// drain - sends buffered requests oldest first, stops on temporary failure to keep samples order.
+// Rejected requests are dropped, receiver would never accept them
+func (p *Pusher) drain(ctx context.Context) error {
+	files, err := p.buf.pending()
+	if err != nil {
+		return fmt.Errorf("%w: buffer: %w", ErrPush, err)
+	}
+
+	errs := []error{}
+
+	for i, file := range files {
+		req, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("%w: buffer: %w", ErrPush, err)
+		}
+
+		temporary, err := p.retry(ctx, func() (bool, error) {
+			return p.sendWrite(ctx, req)
+		})
+		if err != nil && temporary {
+			errs = append(errs, fmt.Errorf("%w, %d requests buffered", err, len(files)-i))
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if err := p.buf.remove(file); err != nil {
+			return fmt.Errorf("%w: buffer: %w", ErrPush, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p *Pusher) sendWrite(ctx context.Context, req []byte) (bool, error) {
+	return p.send(ctx, http.MethodPost, req, http.Header{
+		"Content-Type":                      {"application/x-protobuf"},
+		"Content-Encoding":                  {"snappy"},
+		"X-Prometheus-Remote-Write-Version": {"0.1.0"},
+	})
+}
+
+// send - makes request once, returns true if failure is temporary and worth retry
+func (p *Pusher) send(ctx context.Context, method string, body []byte, header http.Header) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, method, p.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header = header
+
+	switch {
+	case p.opts.bearer != "":
+		req.Header.Set("Authorization", "Bearer "+p.opts.bearer)
+	case p.opts.username != "":
+		req.SetBasicAuth(p.opts.username, p.opts.password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch code := resp.StatusCode; {
+	case code >= 200 && code < 300:
+		return false, nil
+	case code == http.StatusTooManyRequests || code >= 500:
+		return true, fmt.Errorf("status %d: %s", code, bytes.TrimSpace(msg))
+	default:
+		return false, fmt.Errorf("status %d: %s", code, bytes.TrimSpace(msg))
+	}
+}
+
+// retry - calls send until success, permanent failure or retries exhaustion,
+// returns true with error if last failure was temporary
+func (p *Pusher) retry(ctx context.Context, send func() (bool, error)) (bool, error) {
+	backoff := retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		temporary, err := send()
+		if err == nil {
+			return false, nil
+		}
+
+		if !temporary || attempt >= p.opts.retries {
+			return temporary, fmt.Errorf("%w after %d attempts: %w", ErrPush, attempt+1, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return true, fmt.Errorf("%w: %w", ErrPush, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// groupingPath - Pushgateway grouping key path, values with slash are base64 encoded
+func groupingPath(job, instance string) string {
+	path := "/metrics" + groupingLabel("job", job)
+	if instance != "" {
+		path += groupingLabel("instance", instance)
+	}
+	return path
+}
+
+func groupingLabel(name, value string) string {
+	if strings.Contains(value, "/") {
+		return "/" + name + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return "/" + name + "/" + url.PathEscape(value)
+}
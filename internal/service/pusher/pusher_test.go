@@ -0,0 +1,452 @@
+package pusher
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func init() {
+	retryBackoff = time.Millisecond
+}
+
+// rwSample - decoded remote write series
+type rwSample struct {
+	labels map[string]string
+	value  float64
+	ts     int64
+}
+
+// receiver - local remote write and Pushgateway stand-in, status decides answer of every request
+type receiver struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		rc.t.Errorf("read push body: %v", err)
+	}
+
+	rc.mu.Lock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	status := rc.status
+	rc.mu.Unlock()
+
+	if status == 0 {
+		status = http.StatusNoContent
+	}
+	w.WriteHeader(status)
+	if status >= 300 {
+		w.Write([]byte("receiver says no\n"))
+	}
+}
+
+func (rc *receiver) setStatus(code int) {
+	rc.mu.Lock()
+	rc.status = code
+	rc.mu.Unlock()
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.requests)
+}
+
+func serveReceiver(t *testing.T) (*receiver, string) {
+	t.Helper()
+
+	rc := &receiver{t: t}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	return rc, srv.URL
+}
+
+// testRegistry - registry with counter, labeled gauge and histogram
+func testRegistry(t *testing.T) *prometheus.Registry {
+	t.Helper()
+
+	reg := prometheus.NewRegistry()
+
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "xui_exporter_scrapes_total", Help: "h"})
+	c.Add(42)
+
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "xui_client_used_bytes", Help: "h"}, []string{"email", "job"})
+	g.WithLabelValues("alice", "own").Set(1024)
+
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "xui_scrape_seconds", Help: "h", Buckets: []float64{0.5, 1}})
+	h.Observe(0.2)
+	h.Observe(0.7)
+
+	reg.MustRegister(c, g, h)
+	return reg
+}
+
+// decodeWriteRequest - parses snappy compressed remote write protobuf
+func decodeWriteRequest(t *testing.T, body []byte) []rwSample {
+	t.Helper()
+
+	raw, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("remote write body is not snappy: %v", err)
+	}
+
+	samples := []rwSample{}
+	eachField(t, raw, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) {
+		if num != fieldWriteRequestTimeseries {
+			t.Fatalf("unexpected write request field %d", num)
+		}
+
+		s := rwSample{labels: map[string]string{}}
+		eachField(t, v, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) {
+			switch num {
+			case fieldTimeSeriesLabels:
+				var name, value string
+				eachField(t, v, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) {
+					if num == fieldLabelName {
+						name = string(v)
+					} else {
+						value = string(v)
+					}
+				})
+				s.labels[name] = value
+
+			case fieldTimeSeriesSamples:
+				eachField(t, v, func(num protowire.Number, _ protowire.Type, _ []byte, n uint64) {
+					if num == fieldSampleValue {
+						s.value = math.Float64frombits(n)
+					} else {
+						s.ts = int64(n)
+					}
+				})
+			}
+		})
+		samples = append(samples, s)
+	})
+
+	return samples
+}
+
+// eachField - walks protobuf message fields, bytes fields come as v and scalar ones as n
+func eachField(t *testing.T, b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, n uint64)) {
+	t.Helper()
+
+	for len(b) > 0 {
+		num, typ, tn := protowire.ConsumeTag(b)
+		if tn < 0 {
+			t.Fatalf("bad protobuf tag: %v", protowire.ParseError(tn))
+		}
+		b = b[tn:]
+
+		var (
+			v  []byte
+			n  uint64
+			vn int
+		)
+		switch typ {
+		case protowire.BytesType:
+			v, vn = protowire.ConsumeBytes(b)
+		case protowire.Fixed64Type:
+			n, vn = protowire.ConsumeFixed64(b)
+		case protowire.VarintType:
+			n, vn = protowire.ConsumeVarint(b)
+		default:
+			t.Fatalf("unexpected protobuf wire type %d", typ)
+		}
+		if vn < 0 {
+			t.Fatalf("bad protobuf value: %v", protowire.ParseError(vn))
+		}
+		b = b[vn:]
+
+		fn(num, typ, v, n)
+	}
+}
+
+// seriesKey - metric name with sorted label pairs
+func seriesKey(labels map[string]string) string {
+	key := labels["__name__"] + "{"
+	for _, name := range []string{"email", "instance", "job", "le"} {
+		if v, ok := labels[name]; ok {
+			key += name + "=" + v + ","
+		}
+	}
+	return key + "}"
+}
+
+func TestPushRemoteWrite(t *testing.T) {
+	rc, url := serveReceiver(t)
+
+	p, err := NewPusher(ModeRemoteWrite, url+"/api/v1/write", testRegistry(t),
+		WithJob("", "node-1"), WithBearerToken("t0ken"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().UnixMilli()
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	if rc.count() != 1 {
+		t.Fatalf("requests = %d, want 1", rc.count())
+	}
+
+	r := rc.requests[0]
+	if r.Method != http.MethodPost || r.URL.Path != "/api/v1/write" {
+		t.Errorf("request = %s %s", r.Method, r.URL.Path)
+	}
+	for name, want := range map[string]string{
+		"Content-Type":                      "application/x-protobuf",
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+		"Authorization":                     "Bearer t0ken",
+	} {
+		if got := r.Header.Get(name); got != want {
+			t.Errorf("header %s = %q, want %q", name, got, want)
+		}
+	}
+
+	got := map[string]float64{}
+	for _, s := range decodeWriteRequest(t, rc.bodies[0]) {
+		if s.ts < before || s.ts > time.Now().UnixMilli() {
+			t.Errorf("series %v timestamp %d is not push time", s.labels, s.ts)
+		}
+		got[seriesKey(s.labels)] = s.value
+	}
+
+	want := map[string]float64{
+		"xui_exporter_scrapes_total{instance=node-1,job=3xui-exporter,}":        42,
+		"xui_client_used_bytes{email=alice,instance=node-1,job=own,}":           1024,
+		"xui_scrape_seconds_bucket{instance=node-1,job=3xui-exporter,le=0.5,}":  1,
+		"xui_scrape_seconds_bucket{instance=node-1,job=3xui-exporter,le=1,}":    2,
+		"xui_scrape_seconds_bucket{instance=node-1,job=3xui-exporter,le=+Inf,}": 2,
+		"xui_scrape_seconds_sum{instance=node-1,job=3xui-exporter,}":            0.8999999999999999,
+		"xui_scrape_seconds_count{instance=node-1,job=3xui-exporter,}":          2,
+	}
+	if len(got) != len(want) {
+		t.Errorf("series = %v, want %d", got, len(want))
+	}
+	for key, v := range want {
+		if g, ok := got[key]; !ok || math.Abs(g-v) > 1e-9 {
+			t.Errorf("series %s = %v (present %v), want %v", key, g, ok, v)
+		}
+	}
+}
+
+func TestPushgateway(t *testing.T) {
+	rc, url := serveReceiver(t)
+
+	p, err := NewPusher(ModePushgateway, url+"/", testRegistry(t),
+		WithJob("vpn", "10.0.0.1:4500/eu"), WithBasicAuth("user", "pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if rc.count() != 1 {
+		t.Fatalf("requests = %d, want 1", rc.count())
+	}
+
+	r := rc.requests[0]
+	wantPath := "/metrics/job/vpn/instance@base64/" + base64.RawURLEncoding.EncodeToString([]byte("10.0.0.1:4500/eu"))
+	if r.Method != http.MethodPut || r.URL.Path != wantPath {
+		t.Errorf("request = %s %s, want PUT %s", r.Method, r.URL.Path, wantPath)
+	}
+	if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Errorf("basic auth = %q, %q, %v", user, pass, ok)
+	}
+	if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text exposition", ct)
+	}
+
+	parser := expfmt.TextParser{}
+	families, err := parser.TextToMetricFamilies(strings.NewReader(string(rc.bodies[0])))
+	if err != nil {
+		t.Fatalf("body is not text exposition: %v", err)
+	}
+	if v := families["xui_exporter_scrapes_total"].GetMetric()[0].GetCounter().GetValue(); v != 42 {
+		t.Errorf("pushed scrapes_total = %v, want 42", v)
+	}
+	if families["xui_scrape_seconds"].GetMetric()[0].GetHistogram().GetSampleCount() != 2 {
+		t.Errorf("pushed histogram = %v", families["xui_scrape_seconds"])
+	}
+}
+
+func TestPushRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		calls    int
+		ok       bool
+	}{
+		{"temporary failures then success", []int{503, 429, 200}, 3, 3, true},
+		{"retries exhausted", []int{500}, 1, 2, false},
+		{"rejected request is not retried", []int{400}, 3, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statuses[min(calls, len(tt.statuses)-1)])
+				calls++
+			}))
+			defer srv.Close()
+
+			p, err := NewPusher(ModeRemoteWrite, srv.URL, testRegistry(t), WithRetries(tt.retries))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = p.Push(context.Background())
+			if (err == nil) != tt.ok {
+				t.Errorf("Push() error = %v, want success %v", err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, ErrPush) {
+				t.Errorf("Push() error = %v, want %v", err, ErrPush)
+			}
+			if calls != tt.calls {
+				t.Errorf("attempts = %d, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestPushBufferOutage(t *testing.T) {
+	rc, url := serveReceiver(t)
+	dir := t.TempDir()
+
+	p, err := NewPusher(ModeRemoteWrite, url, testRegistry(t), WithBuffer(dir, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc.setStatus(http.StatusServiceUnavailable)
+	for i := 0; i < 3; i++ {
+		if err := p.Push(context.Background()); !errors.Is(err, ErrPush) {
+			t.Fatalf("Push() during outage error = %v, want %v", err, ErrPush)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	if files, _ := os.ReadDir(dir); len(files) != 3 {
+		t.Fatalf("buffered requests = %d, want 3", len(files))
+	}
+
+	rc.setStatus(http.StatusNoContent)
+	outage := rc.count()
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("Push() after outage error = %v", err)
+	}
+
+	// buffered requests are sent oldest first, then the new one
+	delivered := rc.bodies[outage:]
+	if len(delivered) != 4 {
+		t.Fatalf("delivered after outage = %d, want 4", len(delivered))
+	}
+	last := int64(0)
+	for _, body := range delivered {
+		ts := decodeWriteRequest(t, body)[0].ts
+		if ts < last {
+			t.Errorf("buffered requests delivered out of order: %d after %d", ts, last)
+		}
+		last = ts
+	}
+
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("buffer after drain has %d files, want empty", len(files))
+	}
+}
+
+func TestPushBufferDropsRejected(t *testing.T) {
+	rc, url := serveReceiver(t)
+	dir := t.TempDir()
+
+	p, err := NewPusher(ModeRemoteWrite, url, testRegistry(t), WithBuffer(dir, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc.setStatus(http.StatusBadRequest)
+	if err := p.Push(context.Background()); !errors.Is(err, ErrPush) {
+		t.Errorf("Push() rejected error = %v, want %v", err, ErrPush)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("rejected request is kept in buffer, %d files", len(files))
+	}
+}
+
+func TestPushBufferLimit(t *testing.T) {
+	rc, url := serveReceiver(t)
+	dir := t.TempDir()
+
+	// limit fits one request only
+	p, err := NewPusher(ModeRemoteWrite, url, testRegistry(t), WithBuffer(dir, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc.setStatus(http.StatusBadGateway)
+	p.Push(context.Background())
+
+	err = p.Push(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "1 oldest requests dropped") {
+		t.Errorf("Push() over buffer limit error = %v", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("buffered requests = %d, want newest one only", len(files))
+	}
+}
+
+func TestNewPusherErrors(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	if _, err := NewPusher("graphite", "http://localhost:9091", reg); !errors.Is(err, ErrUnknownMode) {
+		t.Errorf("NewPusher() unknown mode error = %v, want %v", err, ErrUnknownMode)
+	}
+	if _, err := NewPusher(ModePushgateway, "pushgateway:9091", reg); err == nil {
+		t.Error("NewPusher() accepted url without scheme")
+	}
+}
+
+func TestGroupingPath(t *testing.T) {
+	tests := []struct {
+		job, instance string
+		want          string
+	}{
+		{"3xui-exporter", "", "/metrics/job/3xui-exporter"},
+		{"vpn", "node 1", "/metrics/job/vpn/instance/node%201"},
+		{"a/b", "node", "/metrics/job@base64/YS9i/instance/node"},
+	}
+
+	for _, tt := range tests {
+		if got := groupingPath(tt.job, tt.instance); got != tt.want {
+			t.Errorf("groupingPath(%q, %q) = %q, want %q", tt.job, tt.instance, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package pusher
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/snappy"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Prometheus remote write protobuf field numbers (prompb)
+const (
+	fieldWriteRequestTimeseries = 1
+
+	fieldTimeSeriesLabels  = 1
+	fieldTimeSeriesSamples = 2
+
+	fieldLabelName  = 1
+	fieldLabelValue = 2
+
+	fieldSampleValue     = 1
+	fieldSampleTimestamp = 2
+)
+
+type label struct {
+	name, value string
+}
+
+type timeSeries struct {
+	labels []label
+	value  float64
+}
+
+// writeRequest - snappy compressed remote write request of families sampled at ts unix milliseconds
+func writeRequest(families []*dto.MetricFamily, extra []label, ts int64) []byte {
+	var buf []byte
+
+	for _, mf := range families {
+		for _, s := range familySeries(mf) {
+			lset := make([]label, 0, len(s.labels)+len(extra))
+			lset = append(lset, s.labels...)
+
+			// own series labels win over pushed job and instance ones
+			for _, l := range extra {
+				if !slices.ContainsFunc(lset, func(o label) bool { return o.name == l.name }) {
+					lset = append(lset, l)
+				}
+			}
+			slices.SortFunc(lset, func(a, b label) int { return strings.Compare(a.name, b.name) })
+
+			buf = protowire.AppendTag(buf, fieldWriteRequestTimeseries, protowire.BytesType)
+			buf = protowire.AppendBytes(buf, encodeSeries(lset, s.value, ts))
+		}
+	}
+
+	return snappy.Encode(nil, buf)
+}
+
+func encodeSeries(lset []label, value float64, ts int64) []byte {
+	var b []byte
+
+	for _, l := range lset {
+		var lb []byte
+		lb = protowire.AppendTag(lb, fieldLabelName, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.name)
+		lb = protowire.AppendTag(lb, fieldLabelValue, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.value)
+
+		b = protowire.AppendTag(b, fieldTimeSeriesLabels, protowire.BytesType)
+		b = protowire.AppendBytes(b, lb)
+	}
+
+	var sb []byte
+	sb = protowire.AppendTag(sb, fieldSampleValue, protowire.Fixed64Type)
+	sb = protowire.AppendFixed64(sb, math.Float64bits(value))
+	sb = protowire.AppendTag(sb, fieldSampleTimestamp, protowire.VarintType)
+	sb = protowire.AppendVarint(sb, uint64(ts))
+
+	b = protowire.AppendTag(b, fieldTimeSeriesSamples, protowire.BytesType)
+	b = protowire.AppendBytes(b, sb)
+
+	return b
+}
+
+// familySeries - flattens family into plain series, summaries and histograms
+// are split into quantile or bucket, sum and count series like on scrape
+func familySeries(mf *dto.MetricFamily) []timeSeries {
+	name := mf.GetName()
+	series := []timeSeries{}
+
+	add := func(metric string, labels []label, value float64) {
+		lset := make([]label, 0, len(labels)+1)
+		lset = append(lset, label{"__name__", metric})
+		lset = append(lset, labels...)
+		series = append(series, timeSeries{labels: lset, value: value})
+	}
+
+	for _, m := range mf.GetMetric() {
+		labels := make([]label, 0, len(m.GetLabel()))
+		for _, lp := range m.GetLabel() {
+			labels = append(labels, label{lp.GetName(), lp.GetValue()})
+		}
+
+		with := func(name, value string) []label {
+			return append(slices.Clone(labels), label{name, value})
+		}
+
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			add(name, labels, m.GetCounter().GetValue())
+
+		case dto.MetricType_GAUGE:
+			add(name, labels, m.GetGauge().GetValue())
+
+		case dto.MetricType_UNTYPED:
+			add(name, labels, m.GetUntyped().GetValue())
+
+		case dto.MetricType_SUMMARY:
+			s := m.GetSummary()
+			for _, q := range s.GetQuantile() {
+				add(name, with("quantile", formatFloat(q.GetQuantile())), q.GetValue())
+			}
+			add(name+"_sum", labels, s.GetSampleSum())
+			add(name+"_count", labels, float64(s.GetSampleCount()))
+
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+			h := m.GetHistogram()
+			inf := false
+			for _, b := range h.GetBucket() {
+				inf = inf || math.IsInf(b.GetUpperBound(), 1)
+				add(name+"_bucket", with("le", formatFloat(b.GetUpperBound())), float64(b.GetCumulativeCount()))
+			}
+			if !inf {
+				add(name+"_bucket", with("le", "+Inf"), float64(h.GetSampleCount()))
+			}
+			add(name+"_sum", labels, h.GetSampleSum())
+			add(name+"_count", labels, float64(h.GetSampleCount()))
+		}
+	}
+
+	return series
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
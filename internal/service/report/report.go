@@ -0,0 +1,254 @@
+package report
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/eterline/x3ui-exporter/internal/service/history"
+)
+
+// Per client usage reports over stored traffic history
+
+const DateLayout = time.DateOnly
+
+var (
+	ErrBadRange  = errors.New("report range end must be after start")
+	ErrBadFormat = errors.New("report format must be csv or json")
+	ErrBadDate   = errors.New("date must be YYYY-MM-DD")
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// RecordSource - traffic history records provider
+type RecordSource interface {
+	Records(ctx context.Context, q history.Query) ([]history.TrafficRecord, error)
+}
+
+// Params - report range and filters, empty filters match everything
+type Params struct {
+	From    time.Time
+	To      time.Time
+	Panel   string
+	Inbound string
+	Email   string
+
+	// Identity - optional client email mapper applied before email filter
+	Identity func(email string) string
+}
+
+type (
+	// ClientUsage - client traffic in panel inbound for report range
+	ClientUsage struct {
+		Panel      string   `json:"panel"`
+		Inbound    string   `json:"inbound"`
+		Email      string   `json:"email"`
+		Protocol   string   `json:"protocol"`
+		Up         uint64   `json:"up"`
+		Down       uint64   `json:"down"`
+		Total      uint64   `json:"total"`
+		PeakDay    string   `json:"peakDay"`
+		PeakBytes  uint64   `json:"peakDayBytes"`
+		Quota      uint64   `json:"quota"`
+		QuotaShare *float64 `json:"quotaShare"`
+	}
+
+	// InboundUsage - summary traffic of panel inbound for report range
+	InboundUsage struct {
+		Panel   string `json:"panel"`
+		Inbound string `json:"inbound"`
+		Clients int    `json:"clients"`
+		Up      uint64 `json:"up"`
+		Down    uint64 `json:"down"`
+		Total   uint64 `json:"total"`
+	}
+)
+
+// Report - usage of every client with traffic in range
+type Report struct {
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Clients  []ClientUsage  `json:"clients"`
+	Inbounds []InboundUsage `json:"inbounds"`
+}
+
+// Build - collects report from daily history rollups in [From, To)
+func Build(ctx context.Context, src RecordSource, p Params) (*Report, error) {
+	if !p.To.After(p.From) {
+		return nil, ErrBadRange
+	}
+
+	records, err := src.Records(ctx, history.Query{
+		Period:  history.Daily,
+		From:    p.From,
+		To:      p.To,
+		Panel:   p.Panel,
+		Inbound: p.Inbound,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read traffic history: %w", err)
+	}
+
+	type key struct{ panel, inbound, email string }
+
+	clients := map[key]*ClientUsage{}
+	inbounds := map[key]*InboundUsage{}
+
+	// records are ordered by start, so last seen quota is actual one
+	for _, rec := range records {
+		email := rec.Email
+		if p.Identity != nil {
+			email = p.Identity(email)
+		}
+		if p.Email != "" && p.Email != email {
+			continue
+		}
+
+		k := key{rec.Panel, rec.Inbound, email}
+		cl, ok := clients[k]
+		if !ok {
+			cl = &ClientUsage{Panel: rec.Panel, Inbound: rec.Inbound, Email: email}
+			clients[k] = cl
+		}
+
+		day := rec.Up + rec.Down
+		cl.Protocol = rec.Protocol
+		cl.Quota = rec.Quota
+		cl.Up += rec.Up
+		cl.Down += rec.Down
+		cl.Total += day
+
+		if day > cl.PeakBytes {
+			cl.PeakBytes = day
+			cl.PeakDay = rec.Start.Format(DateLayout)
+		}
+
+		ik := key{panel: rec.Panel, inbound: rec.Inbound}
+		inb, ok := inbounds[ik]
+		if !ok {
+			inb = &InboundUsage{Panel: rec.Panel, Inbound: rec.Inbound}
+			inbounds[ik] = inb
+		}
+		inb.Up += rec.Up
+		inb.Down += rec.Down
+		inb.Total += day
+	}
+
+	r := &Report{
+		From:     p.From.UTC().Format(DateLayout),
+		To:       p.To.UTC().Format(DateLayout),
+		Clients:  make([]ClientUsage, 0, len(clients)),
+		Inbounds: make([]InboundUsage, 0, len(inbounds)),
+	}
+
+	for k, cl := range clients {
+		if cl.Quota > 0 {
+			share := float64(cl.Total) / float64(cl.Quota)
+			cl.QuotaShare = &share
+		}
+		inbounds[key{panel: k.panel, inbound: k.inbound}].Clients++
+		r.Clients = append(r.Clients, *cl)
+	}
+
+	for _, inb := range inbounds {
+		r.Inbounds = append(r.Inbounds, *inb)
+	}
+
+	slices.SortFunc(r.Clients, func(a, b ClientUsage) int {
+		return cmp.Or(
+			cmp.Compare(a.Panel, b.Panel),
+			cmp.Compare(a.Inbound, b.Inbound),
+			cmp.Compare(a.Email, b.Email),
+		)
+	})
+
+	slices.SortFunc(r.Inbounds, func(a, b InboundUsage) int {
+		return cmp.Or(
+			cmp.Compare(a.Panel, b.Panel),
+			cmp.Compare(a.Inbound, b.Inbound),
+		)
+	})
+
+	return r, nil
+}
+
+var csvHeader = []string{
+	"panel", "inbound", "email", "protocol",
+	"up", "down", "total", "peak_day", "peak_day_bytes", "quota", "quota_share",
+}
+
+// WriteCSV - writes client usage rows as CSV with header
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, cl := range r.Clients {
+		share := ""
+		if cl.QuotaShare != nil {
+			share = strconv.FormatFloat(*cl.QuotaShare, 'f', 4, 64)
+		}
+
+		err := cw.Write([]string{
+			cl.Panel, cl.Inbound, cl.Email, cl.Protocol,
+			u64(cl.Up), u64(cl.Down), u64(cl.Total),
+			cl.PeakDay, u64(cl.PeakBytes), u64(cl.Quota), share,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON - writes whole report as JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Write - writes report in format
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatCSV:
+		return r.WriteCSV(w)
+	case FormatJSON:
+		return r.WriteJSON(w)
+	}
+	return ErrBadFormat
+}
+
+// ParseDate - parses report range bound as UTC midnight.
+// Report is built from daily rollups, so time of day bounds are rejected instead of being silently rounded
+func ParseDate(s string) (time.Time, error) {
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrBadDate, s)
+	}
+	return t, nil
+}
+
+// LastMonth - range of previous calendar month in UTC
+func LastMonth(now time.Time) (from, to time.Time) {
+	to = history.Monthly.Start(now)
+	return to.AddDate(0, -1, 0), to
+}
+
+func u64(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
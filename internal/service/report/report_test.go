@@ -0,0 +1,96 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eterline/x3ui-exporter/internal/service/history"
+)
+
+type recordSource []history.TrafficRecord
+
+func (rs recordSource) Records(_ context.Context, q history.Query) ([]history.TrafficRecord, error) {
+	recs := []history.TrafficRecord{}
+	for _, r := range rs {
+		if r.Period == q.Period && !r.Start.Before(q.From) && r.Start.Before(q.To) {
+			recs = append(recs, r)
+		}
+	}
+	return recs, nil
+}
+
+func day(s string) time.Time {
+	t, _ := time.Parse(DateLayout, s)
+	return t
+}
+
+func TestParseDate(t *testing.T) {
+	got, err := ParseDate("2025-03-01")
+	if err != nil || !got.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseDate() = %v, %v, want 2025-03-01 UTC midnight", got, err)
+	}
+
+	for _, s := range []string{"2025-03-01T12:00:00Z", "2025-03-01T00:00:00+03:00", "01.03.2025", ""} {
+		if _, err := ParseDate(s); !errors.Is(err, ErrBadDate) {
+			t.Errorf("ParseDate(%q) error = %v, want %v", s, err, ErrBadDate)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	src := recordSource{
+		{Period: history.Daily, Start: day("2025-02-28"), Panel: "p", Inbound: "vless", Email: "alice", Up: 1, Down: 1},
+		{Period: history.Daily, Start: day("2025-03-01"), Panel: "p", Inbound: "vless", Email: "alice", Up: 10, Down: 20, Quota: 100},
+		{Period: history.Daily, Start: day("2025-03-02"), Panel: "p", Inbound: "vless", Email: "alice", Up: 5, Down: 5, Quota: 100},
+		{Period: history.Daily, Start: day("2025-03-02"), Panel: "p", Inbound: "vless", Email: "bob", Up: 7, Down: 0},
+		{Period: history.Hourly, Start: day("2025-03-02"), Panel: "p", Inbound: "vless", Email: "bob", Up: 7, Down: 0},
+	}
+
+	rep, err := Build(context.Background(), src, Params{
+		From:     day("2025-03-01"),
+		To:       day("2025-04-01"),
+		Identity: strings.ToUpper,
+	})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if len(rep.Clients) != 2 {
+		t.Fatalf("Build() clients = %+v, want 2", rep.Clients)
+	}
+
+	alice := rep.Clients[0]
+	if alice.Email != "ALICE" || alice.Total != 40 || alice.PeakDay != "2025-03-01" || alice.PeakBytes != 30 {
+		t.Errorf("Build() alice = %+v", alice)
+	}
+	if alice.QuotaShare == nil || *alice.QuotaShare != 0.4 {
+		t.Errorf("Build() alice quota share = %v, want 0.4", alice.QuotaShare)
+	}
+	if rep.Clients[1].QuotaShare != nil {
+		t.Errorf("Build() bob quota share = %v, want none without quota", *rep.Clients[1].QuotaShare)
+	}
+
+	if len(rep.Inbounds) != 1 || rep.Inbounds[0].Clients != 2 || rep.Inbounds[0].Total != 47 {
+		t.Errorf("Build() inbounds = %+v", rep.Inbounds)
+	}
+
+	filtered, err := Build(context.Background(), src, Params{
+		From:     day("2025-03-01"),
+		To:       day("2025-04-01"),
+		Email:    "BOB",
+		Identity: strings.ToUpper,
+	})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if len(filtered.Clients) != 1 || filtered.Clients[0].Email != "BOB" {
+		t.Errorf("Build() email filter by identity = %+v", filtered.Clients)
+	}
+
+	if _, err := Build(context.Background(), src, Params{From: day("2025-03-01"), To: day("2025-03-01")}); !errors.Is(err, ErrBadRange) {
+		t.Errorf("Build() empty range error = %v, want %v", err, ErrBadRange)
+	}
+}
@@ -0,0 +1,171 @@
+package rules
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+)
+
+// rateWindow - range of recorded rates and rate based alerts
+const rateWindow = "5m"
+
+// Params - exporter settings rules must agree with
+type Params struct {
+	// Namespace - exported metrics namespace prefix
+	Namespace string
+	// QuotaShare - client quota usage share firing quota alert
+	QuotaShare float64
+	// ExpiryWarn - client expiry lead time firing expiry alert
+	ExpiryWarn time.Duration
+}
+
+type (
+	// File - Prometheus rules file
+	File struct {
+		Groups []Group `yaml:"groups"`
+	}
+
+	Group struct {
+		Name  string `yaml:"name"`
+		Rules []Rule `yaml:"rules"`
+	}
+
+	Rule struct {
+		Record      string            `yaml:"record,omitempty"`
+		Alert       string            `yaml:"alert,omitempty"`
+		Expr        string            `yaml:"expr"`
+		For         string            `yaml:"for,omitempty"`
+		Labels      map[string]string `yaml:"labels,omitempty"`
+		Annotations map[string]string `yaml:"annotations,omitempty"`
+	}
+)
+
+// Generate - builds alerting and recording rules for exporter metric names
+func Generate(p Params) File {
+	m := func(name string) string {
+		return prometheus.BuildFQName(p.Namespace, "", name)
+	}
+
+	// record - recording rule name in level:metric:operations convention
+	record := func(level, name string) string {
+		return level + ":" + strings.TrimSuffix(m(name), "_total") + ":rate" + rateWindow
+	}
+
+	group := p.Namespace
+	if group == "" {
+		group = "3xui"
+	}
+
+	scrapes := m("exporter_scrapes_total")
+	expiry := m("client_expiry_timestamp_seconds")
+
+	alerts := []Rule{
+		{
+			Alert: "XUIPanelDown",
+			Expr: fmt.Sprintf(
+				`sum without (result) (rate(%s{result="error"}[%s])) > 0 unless sum without (result) (rate(%s{result="success"}[%s])) > 0`,
+				scrapes, rateWindow, scrapes, rateWindow,
+			),
+			For:    "5m",
+			Labels: severity("critical"),
+			Annotations: annotations(
+				"3X-UI panel is unreachable",
+				"Exporter {{ $labels.instance }} failed every panel scrape for 5 minutes.",
+			),
+		},
+		{
+			Alert:  "XUIPushStale",
+			Expr:   fmt.Sprintf(`%s == 1`, m("push_stale")),
+			For:    "1m",
+			Labels: severity("warning"),
+			Annotations: annotations(
+				"3X-UI panel traffic pushes stopped",
+				"Pushes from {{ $labels.source }} to {{ $labels.instance }} did not arrive within staleness window.",
+			),
+		},
+		{
+			Alert: "XUIClientQuotaNearlyExhausted",
+			Expr: fmt.Sprintf(`%s / (%s > 0) >= %g`,
+				m("client_used_bytes"), m("client_quota_bytes"), p.QuotaShare),
+			Labels: severity("warning"),
+			Annotations: annotations(
+				"3X-UI client traffic quota nearly exhausted",
+				"Client {{ $labels.email }} used {{ $value | humanizePercentage }} of traffic quota.",
+			),
+		},
+		{
+			Alert:  "XUIPanelLoginFailures",
+			Expr:   fmt.Sprintf(`increase(%s{result="error"}[15m]) > 2`, m("exporter_login_attempts_total")),
+			Labels: severity("warning"),
+			Annotations: annotations(
+				"3X-UI panel login keeps failing",
+				"Exporter {{ $labels.instance }} failed to log in to panel {{ $value | humanize }} times in 15 minutes.",
+			),
+		},
+	}
+
+	// expiry alert follows expiry event lead time, 0 disables both
+	if p.ExpiryWarn > 0 {
+		alerts = append(alerts, Rule{
+			Alert: "XUIClientExpiring",
+			Expr: fmt.Sprintf(`%s - time() < %.0f and %s - time() > 0`,
+				expiry, p.ExpiryWarn.Seconds(), expiry),
+			Labels: severity("info"),
+			Annotations: annotations(
+				"3X-UI client expires soon",
+				"Client {{ $labels.email }} expires in {{ $value | humanizeDuration }}.",
+			),
+		})
+	}
+
+	records := []Rule{
+		{
+			Record: record("inbound", "inbound_clients_traffic_bytes_total"),
+			Expr: fmt.Sprintf(`sum by (instance, inbound, direction) (rate(%s[%s]))`,
+				m("inbound_clients_traffic_bytes_total"), rateWindow),
+		},
+		{
+			Record: record("inbound", "inbound_traffic_bytes_total"),
+			Expr: fmt.Sprintf(`sum by (instance, tag, direction) (rate(%s[%s]))`,
+				m("inbound_traffic_bytes_total"), rateWindow),
+		},
+		{
+			Record: record("protocol", "protocol_traffic_bytes_total"),
+			Expr: fmt.Sprintf(`sum by (instance, protocol, direction) (rate(%s[%s]))`,
+				m("protocol_traffic_bytes_total"), rateWindow),
+		},
+	}
+
+	return File{
+		Groups: []Group{
+			{Name: group + "-exporter.alerts", Rules: alerts},
+			{Name: group + "-exporter.rules", Rules: records},
+		},
+	}
+}
+
+// Write - writes rules file as YAML
+func (f File) Write(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func severity(level string) map[string]string {
+	return map[string]string{"severity": level}
+}
+
+func annotations(summary, description string) map[string]string {
+	return map[string]string{
+		"summary":     summary,
+		"description": description,
+	}
+}
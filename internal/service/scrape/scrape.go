@@ -2,9 +2,13 @@ package scrape
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 
 	x3uiapi "github.com/eterline/x3ui-exporter/pkg/x3-ui-api"
+	xraystats "github.com/eterline/x3ui-exporter/pkg/xray-stats"
 )
 
 type ClientStat struct {
@@ -13,20 +17,56 @@ type ClientStat struct {
 	Email    string
 	Down     uint64
 	Up       uint64
+	Total    uint64
+
+	Enable        bool
+	InboundEnable bool
+
+	InboundID  int32
+	ExpiryTime int64
+
+	// LimitIP - configured distinct IPs limit, 0 means unlimited
+	LimitIP int
+}
+
+type InboundStat struct {
+	ID         int32
+	Name       string
+	Tag        string
+	Protocol   string
+	Port       int32
+	Enable     bool
+	Down       uint64
+	Up         uint64
+	Total      uint64
+	ExpiryTime int64
+	Clients    int
 }
 
 func (ctf ClientStat) DownTraffic() float64   { return float64(ctf.Down) }
 func (ctf ClientStat) UpTraffic() float64     { return float64(ctf.Up) }
+func (ctf ClientStat) TotalTraffic() float64  { return float64(ctf.Total) }
 func (ctf ClientStat) EmailString() string    { return ctf.Email }
+func (ctf ClientStat) ClientEnabled() bool    { return ctf.Enable }
+func (ctf ClientStat) InboundEnabled() bool   { return ctf.InboundEnable }
 func (itf ClientStat) ProtocolString() string { return itf.Protocol }
 func (itf ClientStat) NameString() string     { return itf.Name }
+func (ctf ClientStat) IPLimit() float64       { return float64(ctf.LimitIP) }
+
+// ExpiryTimestamp - expiry unix time, negative panel expiry is duration after first use and not started yet
+func (ctf ClientStat) ExpiryTimestamp() float64 {
+	if ctf.ExpiryTime <= 0 {
+		return 0
+	}
+	return float64(ctf.ExpiryTime) / 1000
+}
 
 type ScraperXUI struct {
-	api *x3uiapi.XUIClient
+	api DataSource
 	ctx context.Context
 }
 
-func NewScraperXUI(ctx context.Context, c *x3uiapi.XUIClient) *ScraperXUI {
+func NewScraperXUI(ctx context.Context, c DataSource) *ScraperXUI {
 	return &ScraperXUI{
 		api: c,
 		ctx: ctx,
@@ -34,20 +74,42 @@ func NewScraperXUI(ctx context.Context, c *x3uiapi.XUIClient) *ScraperXUI {
 }
 
 func (scr *ScraperXUI) ScrapeInboundStats() ([]ClientStat, error) {
+	_, stats, err := scr.ScrapeInbounds()
+	return stats, err
+}
+
+// ScrapeInbounds - fetches inbounds list with their clients stats
+func (scr *ScraperXUI) ScrapeInbounds() ([]InboundStat, []ClientStat, error) {
 	inbs, err := scr.api.Inbounds(scr.ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed fetch inbounds: %v", err)
+		return nil, nil, fmt.Errorf("failed fetch inbounds: %w", err)
 	}
 
+	inbounds := make([]InboundStat, 0, len(inbs))
 	stats := []ClientStat{}
 
 	for _, inb := range inbs {
+		inbounds = append(inbounds, InboundStat{
+			ID:         inb.ID,
+			Name:       inb.Remark,
+			Tag:        inb.Tag,
+			Protocol:   inb.Protocol,
+			Port:       inb.Port,
+			Enable:     inb.Enable,
+			Up:         uint64(inb.Up),
+			Down:       uint64(inb.Down),
+			Total:      uint64(inb.Total),
+			ExpiryTime: inb.ExpiryTime,
+			Clients:    len(inb.ClientsStats),
+		})
+
 		if !statsAvailable(inb) {
 			continue
 		}
 
 		name := inb.Remark
 		proto := inb.Protocol
+		limits := inb.ClientIPLimits()
 
 		for _, stat := range inb.ClientsStats {
 
@@ -57,13 +119,130 @@ func (scr *ScraperXUI) ScrapeInboundStats() ([]ClientStat, error) {
 				Email:    stat.Email,
 				Up:       uint64(stat.Up),
 				Down:     uint64(stat.Down),
+				Total:    uint64(stat.Total),
+
+				Enable:        stat.Enable,
+				InboundEnable: inb.Enable,
+
+				InboundID:  inb.ID,
+				ExpiryTime: stat.ExpiryTime,
+
+				LimitIP: limits[stat.Email],
 			}
 
 			stats = append(stats, data)
 		}
 	}
 
-	return stats, nil
+	return inbounds, stats, nil
+}
+
+// ScrapeOnline - fetches emails of online clients
+func (scr *ScraperXUI) ScrapeOnline() ([]string, error) {
+	online, err := scr.api.Online(scr.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch online clients: %w", err)
+	}
+	return online, nil
+}
+
+// ScrapeServerStatus - fetches panel server status
+func (scr *ScraperXUI) ScrapeServerStatus() (*x3uiapi.ServerStatus, error) {
+	status, err := scr.api.ServerStatus(scr.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch server status: %w", err)
+	}
+	return status, nil
+}
+
+// ScrapeTraffic - fetches inbound and outbound traffic increase, if source provides it
+func (scr *ScraperXUI) ScrapeTraffic() ([]x3uiapi.InboundTraffic, error) {
+	src, ok := scr.api.(TrafficSource)
+	if !ok {
+		return nil, x3uiapi.ErrNotSupported
+	}
+
+	traffic, err := src.TrafficDeltas(scr.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch traffic: %w", err)
+	}
+	return traffic, nil
+}
+
+// ScrapeOutbounds - fetches panel accumulated outbound traffic, if source provides it
+func (scr *ScraperXUI) ScrapeOutbounds() ([]x3uiapi.OutboundTraffic, error) {
+	src, ok := scr.api.(OutboundSource)
+	if !ok {
+		return nil, x3uiapi.ErrNotSupported
+	}
+
+	traffic, err := src.OutboundsTraffic(scr.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch outbounds traffic: %w", err)
+	}
+	return traffic, nil
+}
+
+// ScrapeClientIPs - fetches client IPs recorded by panel, if source provides them
+func (scr *ScraperXUI) ScrapeClientIPs() (x3uiapi.ClientIPs, error) {
+	src, ok := scr.api.(ClientIPSource)
+	if !ok {
+		return nil, x3uiapi.ErrNotSupported
+	}
+
+	ips, err := src.ClientIPs(scr.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch client ips: %w", err)
+	}
+	return ips, nil
+}
+
+// ScrapeSys - fetches Xray runtime stats, if source provides them
+func (scr *ScraperXUI) ScrapeSys() (*xraystats.SysStats, error) {
+	src, ok := scr.api.(SysSource)
+	if !ok {
+		return nil, x3uiapi.ErrNotSupported
+	}
+
+	sys, err := src.SysStats(scr.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetch xray runtime stats: %w", err)
+	}
+	return sys, nil
+}
+
+// ErrorReason - short scrape failure reason for metrics
+func ErrorReason(err error) string {
+	var (
+		netErr  net.Error
+		jsonErr *json.SyntaxError
+		typeErr *json.UnmarshalTypeError
+	)
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, x3uiapi.ErrLoginFailed):
+		return "login"
+	case errors.Is(err, x3uiapi.ErrBadStatus):
+		return "status"
+	case errors.Is(err, x3uiapi.ErrAPIResponse):
+		return "api"
+	case errors.Is(err, x3uiapi.ErrDatabase):
+		return "database"
+	case errors.Is(err, x3uiapi.ErrNotSupported):
+		return "unsupported"
+	case errors.Is(err, xraystats.ErrXrayAPI):
+		return "xray"
+	case errors.As(err, &jsonErr), errors.As(err, &typeErr):
+		return "decode"
+	default:
+		return "request"
+	}
 }
 
 func statsAvailable(c x3uiapi.Inbound) bool {
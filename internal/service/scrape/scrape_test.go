@@ -0,0 +1,129 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	x3uiapi "github.com/eterline/x3ui-exporter/pkg/x3-ui-api"
+)
+
+func fixtureScraper(t *testing.T, file string) *ScraperXUI {
+	t.Helper()
+
+	fx, err := LoadFixture(file)
+	if err != nil {
+		t.Fatalf("LoadFixture() error = %v", err)
+	}
+	return NewScraperXUI(context.Background(), fx)
+}
+
+func TestFixtureScrapeInbounds(t *testing.T) {
+	scr := fixtureScraper(t, filepath.Join("testdata", "fixture.json"))
+
+	inbounds, stats, err := scr.ScrapeInbounds()
+	if err != nil {
+		t.Fatalf("ScrapeInbounds() error = %v", err)
+	}
+
+	if len(inbounds) != 2 {
+		t.Fatalf("ScrapeInbounds() inbounds = %d, want 2", len(inbounds))
+	}
+	if in := inbounds[0]; in.Name != "vless-main" || in.Up != 3000 || in.Down != 9000 || in.Clients != 2 {
+		t.Errorf("ScrapeInbounds() inbound = %+v", in)
+	}
+
+	want := []ClientStat{
+		{
+			Name: "vless-main", Protocol: "vless", Email: "alice@vpn.example",
+			Up: 1000, Down: 4000, Total: 10737418240,
+			Enable: true, InboundEnable: true,
+			InboundID: 1, ExpiryTime: 1893456000000, LimitIP: 2,
+		},
+		{
+			Name: "vless-main", Protocol: "vless", Email: "bob@vpn.example",
+			Up: 2000, Down: 5000,
+			Enable: false, InboundEnable: true,
+			InboundID: 1, ExpiryTime: -86400000,
+		},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("ScrapeInbounds() stats = %+v, want %+v", stats, want)
+	}
+
+	if got := stats[0].ExpiryTimestamp(); got != 1893456000 {
+		t.Errorf("ExpiryTimestamp() = %v, want 1893456000", got)
+	}
+	if got := stats[1].ExpiryTimestamp(); got != 0 {
+		t.Errorf("ExpiryTimestamp() of not started expiry = %v, want 0", got)
+	}
+}
+
+func TestFixtureScrapeStatus(t *testing.T) {
+	scr := fixtureScraper(t, filepath.Join("testdata", "fixture.json"))
+
+	online, err := scr.ScrapeOnline()
+	if err != nil || !reflect.DeepEqual(online, []string{"alice@vpn.example"}) {
+		t.Errorf("ScrapeOnline() = %v, %v", online, err)
+	}
+
+	status, err := scr.ScrapeServerStatus()
+	if err != nil {
+		t.Fatalf("ScrapeServerStatus() error = %v", err)
+	}
+	if status.CPUCores != 4 || status.Xray.State != "running" || status.Mem.Total != 4294967296 {
+		t.Errorf("ScrapeServerStatus() = %+v", status)
+	}
+}
+
+func TestFixtureUnsupported(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "fixture.json")
+	if err := os.WriteFile(file, []byte(`{"inbounds": []}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	scr := fixtureScraper(t, file)
+
+	_, err := scr.ScrapeOnline()
+	if !errors.Is(err, x3uiapi.ErrNotSupported) {
+		t.Errorf("ScrapeOnline() error = %v, want %v", err, x3uiapi.ErrNotSupported)
+	}
+	if reason := ErrorReason(err); reason != "unsupported" {
+		t.Errorf("ErrorReason() = %q, want unsupported", reason)
+	}
+
+	_, err = scr.ScrapeServerStatus()
+	if !errors.Is(err, x3uiapi.ErrNotSupported) {
+		t.Errorf("ScrapeServerStatus() error = %v, want %v", err, x3uiapi.ErrNotSupported)
+	}
+
+	// fixture has no traffic deltas, outbounds, client IPs or Xray runtime stats
+	if _, err := scr.ScrapeTraffic(); !errors.Is(err, x3uiapi.ErrNotSupported) {
+		t.Errorf("ScrapeTraffic() error = %v, want %v", err, x3uiapi.ErrNotSupported)
+	}
+	if _, err := scr.ScrapeOutbounds(); !errors.Is(err, x3uiapi.ErrNotSupported) {
+		t.Errorf("ScrapeOutbounds() error = %v, want %v", err, x3uiapi.ErrNotSupported)
+	}
+	if _, err := scr.ScrapeClientIPs(); !errors.Is(err, x3uiapi.ErrNotSupported) {
+		t.Errorf("ScrapeClientIPs() error = %v, want %v", err, x3uiapi.ErrNotSupported)
+	}
+	if _, err := scr.ScrapeSys(); !errors.Is(err, x3uiapi.ErrNotSupported) {
+		t.Errorf("ScrapeSys() error = %v, want %v", err, x3uiapi.ErrNotSupported)
+	}
+}
+
+func TestLoadFixtureErrors(t *testing.T) {
+	if _, err := LoadFixture(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadFixture() of missing file succeeded")
+	}
+
+	file := filepath.Join(t.TempDir(), "broken.json")
+	if err := os.WriteFile(file, []byte(`{"inbounds": {`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFixture(file); err == nil {
+		t.Error("LoadFixture() of broken file succeeded")
+	}
+}
@@ -0,0 +1,105 @@
+package scrape
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	x3uiapi "github.com/eterline/x3ui-exporter/pkg/x3-ui-api"
+	xraystats "github.com/eterline/x3ui-exporter/pkg/xray-stats"
+)
+
+// DataSource - panel data provider: panel HTTP API, local panel database,
+// recorded fixture or Xray stats. Data missing in source is reported with x3uiapi.ErrNotSupported
+type DataSource interface {
+	Inbounds(ctx context.Context) ([]x3uiapi.Inbound, error)
+	ClientStats(ctx context.Context) ([]x3uiapi.ClientStats, error)
+	Online(ctx context.Context) (x3uiapi.Online, error)
+	ServerStatus(ctx context.Context) (*x3uiapi.ServerStatus, error)
+}
+
+// TrafficSource - optional inbound and outbound traffic increase since previous call
+type TrafficSource interface {
+	TrafficDeltas(ctx context.Context) ([]x3uiapi.InboundTraffic, error)
+}
+
+// OutboundSource - optional panel accumulated outbound traffic
+type OutboundSource interface {
+	OutboundsTraffic(ctx context.Context) ([]x3uiapi.OutboundTraffic, error)
+}
+
+// ClientIPSource - optional client IPs recorded by panel
+type ClientIPSource interface {
+	ClientIPs(ctx context.Context) (x3uiapi.ClientIPs, error)
+}
+
+// SysSource - optional Xray runtime stats
+type SysSource interface {
+	SysStats(ctx context.Context) (*xraystats.SysStats, error)
+}
+
+var (
+	_ DataSource = (*x3uiapi.XUIClient)(nil)
+	_ DataSource = (*x3uiapi.XUIDatabase)(nil)
+	_ DataSource = (*FixtureSource)(nil)
+	_ DataSource = (*xraystats.Source)(nil)
+
+	_ OutboundSource = (*x3uiapi.XUIClient)(nil)
+	_ OutboundSource = (*x3uiapi.XUIDatabase)(nil)
+
+	_ ClientIPSource = (*x3uiapi.XUIClient)(nil)
+	_ ClientIPSource = (*x3uiapi.XUIDatabase)(nil)
+
+	_ TrafficSource = (*xraystats.Source)(nil)
+	_ SysSource     = (*xraystats.Source)(nil)
+)
+
+// FixtureSource - replays recorded panel responses, same data on every call
+type FixtureSource struct {
+	InboundList []x3uiapi.Inbound     `json:"inbounds"`
+	OnlineList  x3uiapi.Online        `json:"online"`
+	Status      *x3uiapi.ServerStatus `json:"status"`
+}
+
+// LoadFixture - reads fixture from JSON file with inbounds, online and status objects
+// of panel inbounds list, onlines and server status API responses
+func LoadFixture(file string) (*FixtureSource, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	fx := &FixtureSource{}
+	if err := json.Unmarshal(data, fx); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture: %w", err)
+	}
+
+	return fx, nil
+}
+
+func (fx *FixtureSource) Inbounds(ctx context.Context) ([]x3uiapi.Inbound, error) {
+	return fx.InboundList, nil
+}
+
+func (fx *FixtureSource) ClientStats(ctx context.Context) ([]x3uiapi.ClientStats, error) {
+	stats := []x3uiapi.ClientStats{}
+	for _, inb := range fx.InboundList {
+		stats = append(stats, inb.ClientsStats...)
+	}
+	return stats, nil
+}
+
+func (fx *FixtureSource) Online(ctx context.Context) (x3uiapi.Online, error) {
+	if fx.OnlineList == nil {
+		return nil, x3uiapi.ErrNotSupported
+	}
+	return fx.OnlineList, nil
+}
+
+func (fx *FixtureSource) ServerStatus(ctx context.Context) (*x3uiapi.ServerStatus, error) {
+	if fx.Status == nil {
+		return nil, x3uiapi.ErrNotSupported
+	}
+	return fx.Status, nil
+}
@@ -0,0 +1,136 @@
+package state
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/eterline/x3ui-exporter/internal/service/scrape"
+)
+
+// IdentityMapper - converts client email into exposed identity
+type IdentityMapper interface {
+	Identity(email string) string
+}
+
+type (
+	Panel struct {
+		Name       string    `json:"name"`
+		URL        string    `json:"url"`
+		LastScrape time.Time `json:"lastScrape"`
+		Inbounds   int       `json:"inbounds"`
+		Clients    int       `json:"clients"`
+	}
+
+	Inbound struct {
+		Panel    string `json:"panel"`
+		ID       int32  `json:"id"`
+		Name     string `json:"name"`
+		Tag      string `json:"tag"`
+		Protocol string `json:"protocol"`
+		Port     int32  `json:"port"`
+		Enable   bool   `json:"enable"`
+		Up       uint64 `json:"up"`
+		Down     uint64 `json:"down"`
+		Total    uint64 `json:"total"`
+		Quota    uint64 `json:"quota"`
+		Expiry   int64  `json:"expiryTime"`
+		Clients  int    `json:"clients"`
+	}
+
+	Client struct {
+		Panel    string `json:"panel"`
+		Email    string `json:"email"`
+		Inbound  string `json:"inbound"`
+		Protocol string `json:"protocol"`
+		Enable   bool   `json:"enable"`
+		Up       uint64 `json:"up"`
+		Down     uint64 `json:"down"`
+		Total    uint64 `json:"total"`
+		Quota    uint64 `json:"quota"`
+		Expiry   int64  `json:"expiryTime"`
+	}
+)
+
+// Snapshot - immutable traffic state of all panels at last scrape
+type Snapshot struct {
+	Panels   []Panel
+	Inbounds []Inbound
+	Clients  []Client
+}
+
+// Store - keeps last scraped traffic state, readers never block updates
+type Store struct {
+	panel    Panel
+	identity IdentityMapper
+	snap     atomic.Pointer[Snapshot]
+}
+
+// NewStore - creates state store of panel
+func NewStore(name, url string, identity IdentityMapper) *Store {
+	s := &Store{
+		panel:    Panel{Name: name, URL: url},
+		identity: identity,
+	}
+
+	s.snap.Store(&Snapshot{
+		Panels:   []Panel{s.panel},
+		Inbounds: []Inbound{},
+		Clients:  []Client{},
+	})
+
+	return s
+}
+
+// Update - replaces state with new scrape result
+func (s *Store) Update(inbounds []scrape.InboundStat, clients []scrape.ClientStat) {
+	panel := s.panel
+	panel.LastScrape = time.Now()
+	panel.Inbounds = len(inbounds)
+	panel.Clients = len(clients)
+
+	snap := &Snapshot{
+		Panels:   []Panel{panel},
+		Inbounds: make([]Inbound, 0, len(inbounds)),
+		Clients:  make([]Client, 0, len(clients)),
+	}
+
+	for _, inb := range inbounds {
+		snap.Inbounds = append(snap.Inbounds, Inbound{
+			Panel:    panel.Name,
+			ID:       inb.ID,
+			Name:     inb.Name,
+			Tag:      inb.Tag,
+			Protocol: inb.Protocol,
+			Port:     inb.Port,
+			Enable:   inb.Enable,
+			Up:       inb.Up,
+			Down:     inb.Down,
+			Total:    inb.Up + inb.Down,
+			Quota:    inb.Total,
+			Expiry:   inb.ExpiryTime,
+			Clients:  inb.Clients,
+		})
+	}
+
+	for _, cl := range clients {
+		snap.Clients = append(snap.Clients, Client{
+			Panel:    panel.Name,
+			Email:    s.identity.Identity(cl.Email),
+			Inbound:  cl.Name,
+			Protocol: cl.Protocol,
+			Enable:   cl.Enable,
+			Up:       cl.Up,
+			Down:     cl.Down,
+			Total:    cl.Up + cl.Down,
+			Quota:    cl.Total,
+			Expiry:   cl.ExpiryTime,
+		})
+	}
+
+	s.snap.Store(snap)
+}
+
+// Snapshot - returns last traffic state
+func (s *Store) Snapshot() *Snapshot {
+	return s.snap.Load()
+}
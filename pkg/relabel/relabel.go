@@ -0,0 +1,257 @@
+package relabel
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Prometheus-style relabeling of label sets
+
+type Action string
+
+const (
+	Replace   Action = "replace"
+	Keep      Action = "keep"
+	Drop      Action = "drop"
+	LabelMap  Action = "labelmap"
+	LabelDrop Action = "labeldrop"
+)
+
+const (
+	// MetricNameLabel - label holding metric name while relabeling
+	MetricNameLabel = "__name__"
+	// ReservedPrefix - labels with that prefix are removed after relabeling
+	ReservedPrefix = "__"
+
+	defaultSeparator   = ";"
+	defaultRegex       = "(.*)"
+	defaultReplacement = "$1"
+)
+
+var (
+	ErrTargetLabelNeed = errors.New("target_label is required for replace action")
+	ErrUnknownAction   = errors.New("unknown relabel action")
+)
+
+// Config - one relabel rule as it is written in config file
+type Config struct {
+	SourceLabels []string `yaml:"source_labels"`
+	Separator    *string  `yaml:"separator"`
+	Regex        *string  `yaml:"regex"`
+	TargetLabel  string   `yaml:"target_label"`
+	Replacement  *string  `yaml:"replacement"`
+	Action       Action   `yaml:"action"`
+}
+
+type configFile struct {
+	RelabelConfigs []Config `yaml:"relabel_configs"`
+}
+
+type rule struct {
+	sourceLabels []string
+	separator    string
+	regex        *regexp.Regexp
+	targetLabel  string
+	replacement  string
+	action       Action
+}
+
+// Relabeler - compiled relabel rules list
+type Relabeler struct {
+	rules []rule
+}
+
+// New - compiles relabel rules
+func New(cfg ...Config) (*Relabeler, error) {
+	r := &Relabeler{
+		rules: make([]rule, 0, len(cfg)),
+	}
+
+	for i, c := range cfg {
+		rl, err := compile(c)
+		if err != nil {
+			return nil, fmt.Errorf("relabel rule %d: %w", i, err)
+		}
+		r.rules = append(r.rules, rl)
+	}
+
+	return r, nil
+}
+
+// LoadFile - reads and compiles relabel rules from YAML file
+func LoadFile(file string) (*Relabeler, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read relabel config: %w", err)
+	}
+
+	cfg := configFile{}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse relabel config: %w", err)
+	}
+
+	return New(cfg.RelabelConfigs...)
+}
+
+func compile(c Config) (rule, error) {
+	rl := rule{
+		sourceLabels: c.SourceLabels,
+		separator:    valueOr(c.Separator, defaultSeparator),
+		targetLabel:  c.TargetLabel,
+		replacement:  valueOr(c.Replacement, defaultReplacement),
+		action:       c.Action,
+	}
+
+	if rl.action == "" {
+		rl.action = Replace
+	}
+
+	re, err := regexp.Compile("^(?:" + valueOr(c.Regex, defaultRegex) + ")$")
+	if err != nil {
+		return rl, fmt.Errorf("invalid regex: %w", err)
+	}
+	rl.regex = re
+
+	switch rl.action {
+	case Replace:
+		if rl.targetLabel == "" {
+			return rl, ErrTargetLabelNeed
+		}
+	case Keep, Drop, LabelMap, LabelDrop:
+	default:
+		return rl, fmt.Errorf("%w: %s", ErrUnknownAction, rl.action)
+	}
+
+	return rl, nil
+}
+
+// Empty - true if there are no rules to apply
+func (r *Relabeler) Empty() bool {
+	return r == nil || len(r.rules) == 0
+}
+
+// Process - applies rules to label set. Input map is not modified.
+// Returns false when series must be dropped. Reserved labels are removed from result
+func (r *Relabeler) Process(labels map[string]string) (map[string]string, bool) {
+	lset := make(map[string]string, len(labels))
+	for k, v := range labels {
+		lset[k] = v
+	}
+
+	if !r.Empty() {
+		for _, rl := range r.rules {
+			if !rl.apply(lset) {
+				return nil, false
+			}
+		}
+	}
+
+	for k := range lset {
+		if strings.HasPrefix(k, ReservedPrefix) {
+			delete(lset, k)
+		}
+	}
+
+	return lset, true
+}
+
+// Target - name label is exported under after rules, false when series or label is dropped.
+// Label moved by replace rule is recognized as new label with its value or as the only new label
+func (r *Relabeler) Target(labels map[string]string, name string) (string, bool) {
+	out, ok := r.Process(labels)
+	if !ok {
+		return "", false
+	}
+
+	if _, ok := out[name]; ok {
+		return name, true
+	}
+
+	added := []string{}
+	for _, k := range sortedNames(out) {
+		if _, ok := labels[k]; ok {
+			continue
+		}
+		if out[k] == labels[name] {
+			return k, true
+		}
+		added = append(added, k)
+	}
+
+	if len(added) == 1 {
+		return added[0], true
+	}
+	return "", false
+}
+
+func (rl rule) apply(lset map[string]string) bool {
+	switch rl.action {
+	case Keep:
+		return rl.regex.MatchString(rl.sourceValue(lset))
+
+	case Drop:
+		return !rl.regex.MatchString(rl.sourceValue(lset))
+
+	case Replace:
+		src := rl.sourceValue(lset)
+		idx := rl.regex.FindStringSubmatchIndex(src)
+		if idx == nil {
+			return true
+		}
+
+		target := string(rl.regex.ExpandString(nil, rl.targetLabel, src, idx))
+		value := string(rl.regex.ExpandString(nil, rl.replacement, src, idx))
+
+		if value == "" {
+			delete(lset, target)
+			return true
+		}
+		lset[target] = value
+
+	case LabelMap:
+		for _, name := range sortedNames(lset) {
+			if rl.regex.MatchString(name) {
+				lset[rl.regex.ReplaceAllString(name, rl.replacement)] = lset[name]
+			}
+		}
+
+	case LabelDrop:
+		for _, name := range sortedNames(lset) {
+			if rl.regex.MatchString(name) {
+				delete(lset, name)
+			}
+		}
+	}
+
+	return true
+}
+
+func (rl rule) sourceValue(lset map[string]string) string {
+	values := make([]string, len(rl.sourceLabels))
+	for i, name := range rl.sourceLabels {
+		values[i] = lset[name]
+	}
+	return strings.Join(values, rl.separator)
+}
+
+func sortedNames(lset map[string]string) []string {
+	names := make([]string, 0, len(lset))
+	for name := range lset {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func valueOr(v *string, def string) string {
+	if v == nil {
+		return def
+	}
+	return *v
+}
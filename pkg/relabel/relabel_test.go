@@ -0,0 +1,293 @@
+package relabel
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestProcess(t *testing.T) {
+	base := map[string]string{
+		MetricNameLabel:   "xui_client_used_bytes",
+		"email":           "alice@vpn.example",
+		"inbound":         "vless-main",
+		"__client_enable": "true",
+	}
+
+	tests := []struct {
+		name  string
+		rules []Config
+		want  map[string]string
+		keep  bool
+	}{
+		{
+			name: "no rules strips reserved labels",
+			want: map[string]string{"email": "alice@vpn.example", "inbound": "vless-main"},
+			keep: true,
+		},
+		{
+			name: "replace with capture group",
+			rules: []Config{{
+				SourceLabels: []string{"email"},
+				Regex:        ptr("([^@]+)@(.*)"),
+				TargetLabel:  "domain",
+				Replacement:  ptr("$2"),
+			}},
+			want: map[string]string{"email": "alice@vpn.example", "inbound": "vless-main", "domain": "vpn.example"},
+			keep: true,
+		},
+		{
+			name: "replace joins source labels with separator",
+			rules: []Config{{
+				SourceLabels: []string{"inbound", "email"},
+				Separator:    ptr("/"),
+				TargetLabel:  "key",
+			}},
+			want: map[string]string{"email": "alice@vpn.example", "inbound": "vless-main", "key": "vless-main/alice@vpn.example"},
+			keep: true,
+		},
+		{
+			name: "replace with empty value deletes target",
+			rules: []Config{{
+				TargetLabel: "inbound",
+				Replacement: ptr(""),
+			}},
+			want: map[string]string{"email": "alice@vpn.example"},
+			keep: true,
+		},
+		{
+			name: "replace without match keeps labels",
+			rules: []Config{{
+				SourceLabels: []string{"email"},
+				Regex:        ptr("bob@.*"),
+				TargetLabel:  "email",
+				Replacement:  ptr("hidden"),
+			}},
+			want: map[string]string{"email": "alice@vpn.example", "inbound": "vless-main"},
+			keep: true,
+		},
+		{
+			name: "keep by meta label",
+			rules: []Config{{
+				SourceLabels: []string{"__client_enable"},
+				Regex:        ptr("true"),
+				Action:       Keep,
+			}},
+			want: map[string]string{"email": "alice@vpn.example", "inbound": "vless-main"},
+			keep: true,
+		},
+		{
+			name: "keep regex is anchored",
+			rules: []Config{{
+				SourceLabels: []string{"inbound"},
+				Regex:        ptr("vless"),
+				Action:       Keep,
+			}},
+			keep: false,
+		},
+		{
+			name: "drop by metric name",
+			rules: []Config{{
+				SourceLabels: []string{MetricNameLabel},
+				Regex:        ptr(".*_used_bytes"),
+				Action:       Drop,
+			}},
+			keep: false,
+		},
+		{
+			name: "labelmap copies matched labels",
+			rules: []Config{{
+				Regex:       ptr("__client_(.+)"),
+				Replacement: ptr("client_$1"),
+				Action:      LabelMap,
+			}},
+			want: map[string]string{"email": "alice@vpn.example", "inbound": "vless-main", "client_enable": "true"},
+			keep: true,
+		},
+		{
+			name: "labeldrop removes matched labels",
+			rules: []Config{{
+				Regex:  ptr("in.*"),
+				Action: LabelDrop,
+			}},
+			want: map[string]string{"email": "alice@vpn.example"},
+			keep: true,
+		},
+		{
+			name: "rules apply in order",
+			rules: []Config{
+				{SourceLabels: []string{"email"}, Regex: ptr("([^@]+)@.*"), TargetLabel: "user"},
+				{SourceLabels: []string{"user"}, Regex: ptr("alice"), Action: Drop},
+			},
+			keep: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(tt.rules...)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			got, keep := r.Process(base)
+			if keep != tt.keep {
+				t.Fatalf("Process() keep = %v, want %v", keep, tt.keep)
+			}
+			if keep && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Process() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if len(base) != 4 || base["__client_enable"] != "true" {
+		t.Errorf("Process() modified input labels: %v", base)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Config
+		want error
+	}{
+		{
+			name: "replace without target",
+			rule: Config{SourceLabels: []string{"email"}},
+			want: ErrTargetLabelNeed,
+		},
+		{
+			name: "unknown action",
+			rule: Config{Action: "hashmod"},
+			want: ErrUnknownAction,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.rule); !errors.Is(err, tt.want) {
+				t.Errorf("New() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := New(Config{Regex: ptr("("), Action: Keep}); err == nil {
+		t.Error("New() accepted invalid regex")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "relabel.yaml")
+	data := `
+relabel_configs:
+  - source_labels: [email]
+    regex: '(.+)@.*'
+    target_label: email
+  - regex: inbound
+    action: labeldrop
+`
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := LoadFile(file)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	got, keep := r.Process(map[string]string{"email": "alice@vpn.example", "inbound": "vless-main"})
+	want := map[string]string{"email": "alice"}
+	if !keep || !reflect.DeepEqual(got, want) {
+		t.Errorf("Process() = %v, %v, want %v", got, keep, want)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	var r *Relabeler
+	if !r.Empty() {
+		t.Error("nil Relabeler is not empty")
+	}
+
+	got, keep := r.Process(map[string]string{"__meta": "x", "email": "a"})
+	if !keep || !reflect.DeepEqual(got, map[string]string{"email": "a"}) {
+		t.Errorf("nil Relabeler Process() = %v, %v", got, keep)
+	}
+}
+
+func TestTarget(t *testing.T) {
+	base := map[string]string{
+		MetricNameLabel: "xui_client_used_bytes",
+		"email":         "alice@vpn.example",
+		"__email":       "alice@vpn.example",
+	}
+
+	tests := []struct {
+		name  string
+		rules []Config
+		want  string
+		ok    bool
+	}{
+		{
+			name: "no rules",
+			want: "email",
+			ok:   true,
+		},
+		{
+			name: "renamed by replace and labeldrop",
+			rules: []Config{
+				{SourceLabels: []string{"email"}, TargetLabel: "client"},
+				{Regex: ptr("email"), Action: LabelDrop},
+			},
+			want: "client",
+			ok:   true,
+		},
+		{
+			name: "renamed with changed value and extra label",
+			rules: []Config{
+				{SourceLabels: []string{"email"}, Regex: ptr("([^@]+)@.*"), TargetLabel: "user"},
+				{TargetLabel: "region", Replacement: ptr("eu")},
+				{Regex: ptr("email"), Action: LabelDrop},
+			},
+			ok: false,
+		},
+		{
+			name: "renamed with changed value",
+			rules: []Config{
+				{SourceLabels: []string{"email"}, Regex: ptr("([^@]+)@.*"), TargetLabel: "user"},
+				{Regex: ptr("email"), Action: LabelDrop},
+			},
+			want: "user",
+			ok:   true,
+		},
+		{
+			name:  "dropped label",
+			rules: []Config{{Regex: ptr("email"), Action: LabelDrop}},
+			ok:    false,
+		},
+		{
+			name:  "dropped series",
+			rules: []Config{{SourceLabels: []string{MetricNameLabel}, Regex: ptr("xui_client_.*"), Action: Drop}},
+			ok:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(tt.rules...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok := r.Target(base, "email")
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Target() = %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -21,6 +21,10 @@ const (
 var (
 	ErrNilCookieList = errors.New("nill cookie list")
 	ErrCookieNotSet  = errors.New("session cookie did not set")
+	ErrLoginFailed   = errors.New("login failed")
+	ErrBadStatus     = errors.New("bad status code")
+	ErrAPIResponse   = errors.New("api response error")
+	ErrNotSupported  = errors.New("not supported by data source")
 )
 
 type XUIClient struct {
@@ -28,6 +32,7 @@ type XUIClient struct {
 	form       url.Values
 	cookie     *atomic.Pointer[http.Cookie]
 	httpClient *http.Client
+	loginHook  func(err error)
 }
 
 func NewClient(api, sub, user, password string, caFile string) (*XUIClient, error) {
@@ -68,6 +73,11 @@ func NewClient(api, sub, user, password string, caFile string) (*XUIClient, erro
 	return cl, nil
 }
 
+// OnLogin - set hook called after every login attempt with its result
+func (xc *XUIClient) OnLogin(hook func(err error)) {
+	xc.loginHook = hook
+}
+
 func (xc *XUIClient) swapCookie(c []*http.Cookie) error {
 
 	if c == nil {
@@ -122,12 +132,12 @@ func (xc *XUIClient) requestLogin(ctx context.Context) error {
 	defer resp.Body.Close()
 
 	if err := xc.swapCookie(resp.Cookies()); err != nil {
-		return fmt.Errorf("login failed: %w", err)
+		return fmt.Errorf("%w: %w", ErrLoginFailed, err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("login failed: %s", string(body))
+		return fmt.Errorf("%w: %s", ErrLoginFailed, string(body))
 	}
 
 	return nil
@@ -137,6 +147,9 @@ func (xc *XUIClient) newRequest(ctx context.Context, path ...string) (*xuiReques
 
 	if xc.cookieIsExpired() {
 		err := xc.requestLogin(ctx)
+		if xc.loginHook != nil {
+			xc.loginHook(err)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -173,7 +186,7 @@ func (xc *XUIClient) Inbounds(ctx context.Context) ([]Inbound, error) {
 	}
 
 	if code > 299 || code < 199 {
-		return data.Object, fmt.Errorf("bad status code: %d", code)
+		return data.Object, fmt.Errorf("%w: %d", ErrBadStatus, code)
 	}
 
 	if err := req.resolve(&data); err != nil {
@@ -181,7 +194,7 @@ func (xc *XUIClient) Inbounds(ctx context.Context) ([]Inbound, error) {
 	}
 
 	if !data.Success {
-		return data.Object, errors.New(data.Message)
+		return data.Object, fmt.Errorf("%w: %s", ErrAPIResponse, data.Message)
 	}
 
 	return data.Object, nil
@@ -201,7 +214,83 @@ func (xc *XUIClient) Online(ctx context.Context) (Online, error) {
 	}
 
 	if code > 299 || code < 199 {
-		return data.Object, fmt.Errorf("bad status code: %d", code)
+		return data.Object, fmt.Errorf("%w: %d", ErrBadStatus, code)
+	}
+
+	if err := req.resolve(&data); err != nil {
+		return data.Object, err
+	}
+
+	if !data.Success {
+		return data.Object, fmt.Errorf("%w: %s", ErrAPIResponse, data.Message)
+	}
+
+	return data.Object, nil
+}
+
+// ClientStats - client stats of every inbound
+func (xc *XUIClient) ClientStats(ctx context.Context) ([]ClientStats, error) {
+	inbounds, err := xc.Inbounds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := []ClientStats{}
+	for _, inb := range inbounds {
+		stats = append(stats, inb.ClientsStats...)
+	}
+
+	return stats, nil
+}
+
+func (xc *XUIClient) ServerStatus(ctx context.Context) (*ServerStatus, error) {
+
+	data := WrapAPI[*ServerStatus]{}
+	req, err := xc.newRequest(ctx, "server", "status")
+	if err != nil {
+		return data.Object, err
+	}
+
+	code, err := req.post(nil, false)
+	if err != nil {
+		return data.Object, err
+	}
+
+	if code > 299 || code < 199 {
+		return data.Object, fmt.Errorf("%w: %d", ErrBadStatus, code)
+	}
+
+	if err := req.resolve(&data); err != nil {
+		return data.Object, err
+	}
+
+	if !data.Success {
+		return data.Object, fmt.Errorf("%w: %s", ErrAPIResponse, data.Message)
+	}
+
+	return data.Object, nil
+}
+
+// OutboundsTraffic - panel accumulated outbound traffic, missing on old panel versions
+func (xc *XUIClient) OutboundsTraffic(ctx context.Context) ([]OutboundTraffic, error) {
+
+	data := WrapAPI[[]OutboundTraffic]{}
+	req, err := xc.newRequest(ctx, "panel", "xray", "getOutboundsTraffic")
+	if err != nil {
+		return data.Object, err
+	}
+
+	code, err := req.post(nil, false)
+	if err != nil {
+		return data.Object, err
+	}
+
+	if code == http.StatusNotFound {
+		return data.Object, ErrNotSupported
+	}
+
+	if code > 299 || code < 199 {
+		return data.Object, fmt.Errorf("%w: %d", ErrBadStatus, code)
 	}
 
 	if err := req.resolve(&data); err != nil {
@@ -209,8 +298,57 @@ func (xc *XUIClient) Online(ctx context.Context) (Online, error) {
 	}
 
 	if !data.Success {
-		return data.Object, errors.New(data.Message)
+		return data.Object, fmt.Errorf("%w: %s", ErrAPIResponse, data.Message)
 	}
 
 	return data.Object, nil
 }
+
+// ClientIPs - IPs recorded by panel for clients with limitIp set, one request per client
+func (xc *XUIClient) ClientIPs(ctx context.Context) (ClientIPs, error) {
+	inbounds, err := xc.Inbounds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := ClientIPs{}
+	for _, inb := range inbounds {
+		for email := range inb.ClientIPLimits() {
+			list, err := xc.clientIPs(ctx, email)
+			if err != nil {
+				return nil, err
+			}
+			ips[email] = list
+		}
+	}
+
+	return ips, nil
+}
+
+func (xc *XUIClient) clientIPs(ctx context.Context, email string) ([]string, error) {
+
+	data := WrapAPI[string]{}
+	req, err := xc.newRequest(ctx, "panel", "api", "inbounds", "clientIps", email)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := req.post(nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if code > 299 || code < 199 {
+		return nil, fmt.Errorf("%w: %d", ErrBadStatus, code)
+	}
+
+	if err := req.resolve(&data); err != nil {
+		return nil, err
+	}
+
+	if !data.Success {
+		return nil, fmt.Errorf("%w: %s", ErrAPIResponse, data.Message)
+	}
+
+	return parseIPList(data.Object), nil
+}
@@ -0,0 +1,201 @@
+package x3uiapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	ErrDatabase = errors.New("panel database error")
+)
+
+// inboundRow - inbounds table columns stable across 3X-UI versions
+type inboundRow struct {
+	ID             int32
+	Up             int64
+	Down           int64
+	Total          int64
+	Remark         string
+	Enable         bool
+	ExpiryTime     int64
+	Listen         string
+	Port           int32
+	Protocol       string
+	Settings       string
+	StreamSettings string
+	Tag            string
+	Sniffing       string
+}
+
+func (inboundRow) TableName() string { return "inbounds" }
+
+// clientTrafficRow - client_traffics table columns stable across 3X-UI versions
+type clientTrafficRow struct {
+	ID         int32
+	InboundID  int64
+	Enable     bool
+	Email      string
+	Up         int64
+	Down       int64
+	ExpiryTime int64
+	Total      int64
+	Reset      int64
+}
+
+func (clientTrafficRow) TableName() string { return "client_traffics" }
+
+func (OutboundTraffic) TableName() string { return "outbound_traffics" }
+
+// clientIPsRow - inbound_client_ips table, IPs recorded by panel from Xray access log
+type clientIPsRow struct {
+	ClientEmail string
+	IPs         string `gorm:"column:ips"`
+}
+
+func (clientIPsRow) TableName() string { return "inbound_client_ips" }
+
+// XUIDatabase - read-only reader of local 3X-UI panel database (x-ui.db).
+// Panel keeps database in WAL mode, so reads never block panel writes
+type XUIDatabase struct {
+	db *gorm.DB
+}
+
+// OpenDatabase - opens panel database file in read-only query mode
+func OpenDatabase(path string, log logger.Interface) (*XUIDatabase, error) {
+	dsn := (&url.URL{
+		Scheme:   "file",
+		Opaque:   path,
+		RawQuery: "mode=ro&_pragma=query_only(1)&_pragma=busy_timeout(5000)",
+	}).String()
+
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger:                 log,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDatabase, err)
+	}
+
+	if err := db.Exec("SELECT 1 FROM inbounds LIMIT 1").Error; err != nil {
+		return nil, fmt.Errorf("%w: not a 3x-ui database: %w", ErrDatabase, err)
+	}
+
+	return &XUIDatabase{db: db}, nil
+}
+
+func (xd *XUIDatabase) Close() error {
+	db, err := xd.db.DB()
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
+// ClientStats - reads client stats of every inbound
+func (xd *XUIDatabase) ClientStats(ctx context.Context) ([]ClientStats, error) {
+	traffics := []clientTrafficRow{}
+	if err := xd.db.WithContext(ctx).Order("id").Find(&traffics).Error; err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDatabase, err)
+	}
+
+	stats := make([]ClientStats, 0, len(traffics))
+	for _, t := range traffics {
+		stats = append(stats, ClientStats(t))
+	}
+
+	return stats, nil
+}
+
+// OutboundsTraffic - reads outbound traffic, table is missing on old panel versions
+func (xd *XUIDatabase) OutboundsTraffic(ctx context.Context) ([]OutboundTraffic, error) {
+	tx := xd.db.WithContext(ctx)
+
+	if !tx.Migrator().HasTable(&OutboundTraffic{}) {
+		return nil, ErrNotSupported
+	}
+
+	traffic := []OutboundTraffic{}
+	if err := tx.Order("id").Find(&traffic).Error; err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDatabase, err)
+	}
+
+	return traffic, nil
+}
+
+// ClientIPs - IPs recorded by panel, table is missing on old panel versions
+func (xd *XUIDatabase) ClientIPs(ctx context.Context) (ClientIPs, error) {
+	tx := xd.db.WithContext(ctx)
+
+	if !tx.Migrator().HasTable(&clientIPsRow{}) {
+		return nil, ErrNotSupported
+	}
+
+	rows := []clientIPsRow{}
+	if err := tx.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDatabase, err)
+	}
+
+	ips := make(ClientIPs, len(rows))
+	for _, r := range rows {
+		ips[r.ClientEmail] = parseIPList(r.IPs)
+	}
+
+	return ips, nil
+}
+
+// Online - online clients are known only to running panel
+func (xd *XUIDatabase) Online(ctx context.Context) (Online, error) {
+	return nil, ErrNotSupported
+}
+
+// ServerStatus - server status is known only to running panel
+func (xd *XUIDatabase) ServerStatus(ctx context.Context) (*ServerStatus, error) {
+	return nil, ErrNotSupported
+}
+
+// Inbounds - reads inbounds with their client stats, same as panel inbounds list API
+func (xd *XUIDatabase) Inbounds(ctx context.Context) ([]Inbound, error) {
+	rows := []inboundRow{}
+	if err := xd.db.WithContext(ctx).Order("id").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDatabase, err)
+	}
+
+	stats, err := xd.ClientStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make(map[int64][]ClientStats, len(rows))
+	for _, st := range stats {
+		clients[st.InboundID] = append(clients[st.InboundID], st)
+	}
+
+	inbounds := make([]Inbound, 0, len(rows))
+	for _, r := range rows {
+		inbounds = append(inbounds, Inbound{
+			ID:             r.ID,
+			Up:             r.Up,
+			Down:           r.Down,
+			Total:          r.Total,
+			Remark:         r.Remark,
+			Enable:         r.Enable,
+			ExpiryTime:     r.ExpiryTime,
+			ClientsStats:   clients[int64(r.ID)],
+			Listen:         r.Listen,
+			Port:           r.Port,
+			Protocol:       r.Protocol,
+			Settings:       r.Settings,
+			StreamSettings: r.StreamSettings,
+			Tag:            r.Tag,
+			Sniffing:       r.Sniffing,
+		})
+	}
+
+	return inbounds, nil
+}
@@ -5,7 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
+
+	"github.com/google/uuid"
 )
 
 var (
@@ -13,17 +17,25 @@ var (
 )
 
 type StatsMessage struct {
+	ID      string
 	Updates TrafficUpdates
+	Source  string
 	Err     error
 }
 
 type StatsHandle struct {
 	statChan chan StatsMessage
+
+	// trusted - proxy networks allowed to set push sender by X-Real-IP header
+	trusted []netip.Prefix
 }
 
-func NewStatsHandler() *StatsHandle {
+// NewStatsHandler - creates panel push handler,
+// X-Real-IP header is taken as push source only from trusted proxy networks
+func NewStatsHandler(trusted ...netip.Prefix) *StatsHandle {
 	return &StatsHandle{
 		statChan: make(chan StatsMessage),
+		trusted:  trusted,
 	}
 }
 
@@ -31,8 +43,13 @@ func (sl *StatsHandle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	stat := TrafficUpdates{}
 	err := json.NewDecoder(r.Body).Decode(&stat)
 
+	id := uuid.NewString()
+	stat.stampPush(id)
+
 	msg := StatsMessage{
+		ID:      id,
 		Updates: stat,
+		Source:  RequestSource(r, sl.trusted),
 		Err:     nil,
 	}
 
@@ -44,6 +61,53 @@ func (sl *StatsHandle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// RequestSource - returns request sender address without port.
+// X-Real-IP header is used only if request came from trusted proxy, otherwise any client could spoof it
+func RequestSource(r *http.Request, trusted []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+
+	for _, p := range trusted {
+		if !p.Contains(addr) {
+			continue
+		}
+		if real, err := netip.ParseAddr(r.Header.Get("X-Real-IP")); err == nil {
+			return real.Unmap().String()
+		}
+		break
+	}
+
+	return addr.String()
+}
+
+// ParseTrusted - parses trusted proxy CIDRs, plain addresses are taken as single host networks
+func ParseTrusted(list []string) ([]netip.Prefix, error) {
+	trusted := make([]netip.Prefix, 0, len(list))
+
+	for _, s := range list {
+		if addr, err := netip.ParseAddr(s); err == nil {
+			trusted = append(trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad trusted proxy %q: %w", s, err)
+		}
+		trusted = append(trusted, p.Masked())
+	}
+
+	return trusted, nil
+}
+
 func (sl *StatsHandle) Updates(ctx context.Context) <-chan StatsMessage {
 	updateChan := make(chan StatsMessage)
 
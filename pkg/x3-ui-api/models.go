@@ -1,5 +1,11 @@
 package x3uiapi
 
+import (
+	"encoding/json"
+	"net"
+	"strings"
+)
+
 type WrapAPI[T interface{}] struct {
 	Success bool   `json:"success"`
 	Message string `json:"msg"`
@@ -23,10 +29,10 @@ type (
 		ID             int32         `json:"id"`
 		Up             int64         `json:"up"`
 		Down           int64         `json:"down"`
-		Total          int32         `json:"total"`
+		Total          int64         `json:"total"`
 		Remark         string        `json:"remark"`
 		Enable         bool          `json:"enable"`
-		ExpiryTime     int32         `json:"expiryTime"`
+		ExpiryTime     int64         `json:"expiryTime"`
 		ClientsStats   []ClientStats `json:"clientStats"`
 		Listen         string        `json:"listen"`
 		Port           int32         `json:"port"`
@@ -41,6 +47,49 @@ type (
 
 type Online []string
 
+// OutboundTraffic - panel accumulated outbound traffic
+type OutboundTraffic struct {
+	ID    int32  `json:"id"`
+	Tag   string `json:"tag"`
+	Up    int64  `json:"up"`
+	Down  int64  `json:"down"`
+	Total int64  `json:"total"`
+}
+
+type (
+	UsageStat struct {
+		Current uint64 `json:"current"`
+		Total   uint64 `json:"total"`
+	}
+
+	XrayState struct {
+		State    string `json:"state"`
+		ErrorMsg string `json:"errorMsg"`
+		Version  string `json:"version"`
+	}
+
+	ServerStatus struct {
+		CPU      float64   `json:"cpu"`
+		CPUCores int       `json:"cpuCores"`
+		Mem      UsageStat `json:"mem"`
+		Swap     UsageStat `json:"swap"`
+		Disk     UsageStat `json:"disk"`
+		Xray     XrayState `json:"xray"`
+		Uptime   uint64    `json:"uptime"`
+		Loads    []float64 `json:"loads"`
+		TCPCount int       `json:"tcpCount"`
+		UDPCount int       `json:"udpCount"`
+		NetIO    struct {
+			Up   uint64 `json:"up"`
+			Down uint64 `json:"down"`
+		} `json:"netIO"`
+		NetTraffic struct {
+			Sent uint64 `json:"sent"`
+			Recv uint64 `json:"recv"`
+		} `json:"netTraffic"`
+	}
+)
+
 type (
 	ClientTraffic struct {
 		ID         uint64 `json:"id"`
@@ -52,6 +101,8 @@ type (
 		ExpiryTime uint64 `json:"expiryTime"`
 		Total      uint64 `json:"total"`
 		Reset      uint64 `json:"reset"`
+
+		Push string `json:"-"`
 	}
 
 	InboundTraffic struct {
@@ -60,6 +111,8 @@ type (
 		Tag        string `json:"Tag"`
 		Up         uint64 `json:"Up"`
 		Down       uint64 `json:"Down"`
+
+		Push string `json:"-"`
 	}
 
 	TrafficUpdates struct {
@@ -68,11 +121,95 @@ type (
 	}
 )
 
+// stampPush - marks every traffic entry with push id
+func (tu *TrafficUpdates) stampPush(id string) {
+	for i := range tu.Client {
+		tu.Client[i].Push = id
+	}
+	for i := range tu.Inbound {
+		tu.Inbound[i].Push = id
+	}
+}
+
 func (ctf ClientTraffic) DownTraffic() float64  { return float64(ctf.Down) }
 func (ctf ClientTraffic) UpTraffic() float64    { return float64(ctf.Up) }
 func (ctf ClientTraffic) TotalTraffic() float64 { return float64(ctf.Total) }
 func (ctf ClientTraffic) EmailString() string   { return ctf.Email }
+func (ctf ClientTraffic) PushID() string        { return ctf.Push }
 
 func (itf InboundTraffic) DownTraffic() float64 { return float64(itf.Down) }
 func (itf InboundTraffic) UpTraffic() float64   { return float64(itf.Up) }
 func (itf InboundTraffic) TagString() string    { return itf.Tag }
+func (itf InboundTraffic) PushID() string       { return itf.Push }
+
+func (otf OutboundTraffic) DownTraffic() float64 { return float64(otf.Down) }
+func (otf OutboundTraffic) UpTraffic() float64   { return float64(otf.Up) }
+func (otf OutboundTraffic) TagString() string    { return otf.Tag }
+
+// InboundSettings - client part of inbound settings JSON
+type InboundSettings struct {
+	Clients []struct {
+		Email   string `json:"email"`
+		LimitIP int    `json:"limitIp"`
+	} `json:"clients"`
+}
+
+// ClientIPLimits - configured limitIp by client email, clients without limit are omitted
+func (inb Inbound) ClientIPLimits() map[string]int {
+	settings := InboundSettings{}
+	if err := json.Unmarshal([]byte(inb.Settings), &settings); err != nil {
+		return nil
+	}
+
+	limits := map[string]int{}
+	for _, cl := range settings.Clients {
+		if cl.LimitIP > 0 {
+			limits[cl.Email] = cl.LimitIP
+		}
+	}
+	return limits
+}
+
+// ClientIPs - IPs recorded by panel per client email
+type ClientIPs map[string][]string
+
+// parseIPList - panel stores client IPs as JSON array of strings or of objects with ip field,
+// API may answer with plain text list, entries can have "(last seen time)" suffix
+func parseIPList(raw string) []string {
+	items := []json.RawMessage{}
+	values := []string{}
+
+	if err := json.Unmarshal([]byte(raw), &items); err == nil {
+		for _, item := range items {
+			var s string
+			if json.Unmarshal(item, &s) == nil {
+				values = append(values, s)
+				continue
+			}
+
+			var obj struct {
+				IP string `json:"ip"`
+			}
+			if json.Unmarshal(item, &obj) == nil {
+				values = append(values, obj.IP)
+			}
+		}
+	} else {
+		values = strings.FieldsFunc(raw, func(r rune) bool {
+			return r == '\n' || r == ','
+		})
+	}
+
+	ips := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if i := strings.IndexAny(v, " ("); i > 0 {
+			v = v[:i]
+		}
+		if net.ParseIP(v) != nil {
+			ips = append(ips, v)
+		}
+	}
+
+	return ips
+}
@@ -0,0 +1,133 @@
+package xraystats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+
+	statSeparator = ">>>"
+	statTraffic   = "traffic"
+	statUplink    = "uplink"
+	statDownlink  = "downlink"
+)
+
+var (
+	ErrXrayAPI = errors.New("xray api error")
+)
+
+// Stat kinds, first part of Xray stat name
+const (
+	KindUser     = "user"
+	KindInbound  = "inbound"
+	KindOutbound = "outbound"
+)
+
+// Traffic - parsed Xray traffic stat counter
+type Traffic struct {
+	Kind   string
+	Name   string
+	Uplink bool
+	Value  uint64
+}
+
+// ParseStat - parses kind>>>name>>>traffic>>>uplink|downlink stat name
+func ParseStat(name string) (kind, object string, uplink, ok bool) {
+	parts := strings.Split(name, statSeparator)
+	if len(parts) != 4 || parts[2] != statTraffic {
+		return "", "", false, false
+	}
+
+	switch parts[3] {
+	case statUplink:
+		uplink = true
+	case statDownlink:
+	default:
+		return "", "", false, false
+	}
+
+	return parts[0], parts[1], uplink, true
+}
+
+// Client - Xray StatsService gRPC client
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+// NewClient - creates client of Xray API address host:port, connection is established lazily
+func NewClient(addr string) (*Client, error) {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(codec{})),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrXrayAPI, err)
+	}
+
+	return &Client{conn: conn}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// QueryStats - returns stats with name matching pattern, empty pattern matches all.
+// With reset Xray zeroes returned counters, so values are increase since previous resetting query of any reader
+func (c *Client) QueryStats(ctx context.Context, pattern string, reset bool) ([]Stat, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	resp := &queryStatsResponse{}
+	err := c.conn.Invoke(ctx, methodQueryStats, &queryStatsRequest{Pattern: pattern, Reset: reset}, resp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrXrayAPI, err)
+	}
+
+	return resp.Stats, nil
+}
+
+// Traffic - returns every traffic counter, reset is passed to QueryStats
+func (c *Client) Traffic(ctx context.Context, reset bool) ([]Traffic, error) {
+	stats, err := c.QueryStats(ctx, "", reset)
+	if err != nil {
+		return nil, err
+	}
+
+	traffic := make([]Traffic, 0, len(stats))
+	for _, st := range stats {
+		kind, name, uplink, ok := ParseStat(st.Name)
+		if !ok {
+			continue
+		}
+
+		traffic = append(traffic, Traffic{
+			Kind:   kind,
+			Name:   name,
+			Uplink: uplink,
+			Value:  uint64(max(st.Value, 0)),
+		})
+	}
+
+	return traffic, nil
+}
+
+// SysStats - returns Xray runtime stats
+func (c *Client) SysStats(ctx context.Context) (*SysStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	resp := &SysStats{}
+	if err := c.conn.Invoke(ctx, methodGetSysStats, &sysStatsRequest{}, resp); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrXrayAPI, err)
+	}
+
+	return resp, nil
+}
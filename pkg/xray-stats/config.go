@@ -0,0 +1,79 @@
+package xraystats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+var (
+	ErrNoAPIInbound = errors.New("xray config has no api inbound")
+)
+
+type xrayConfig struct {
+	API struct {
+		Tag    string `json:"tag"`
+		Listen string `json:"listen"`
+	} `json:"api"`
+	Inbounds []struct {
+		Tag    string          `json:"tag"`
+		Listen string          `json:"listen"`
+		Port   json.RawMessage `json:"port"`
+	} `json:"inbounds"`
+}
+
+// AddressFromConfig - reads Xray API address from Xray config file,
+// either api.listen or listen and port of inbound tagged as api.tag
+func AddressFromConfig(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	cfg := xrayConfig{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return "", fmt.Errorf("failed to parse xray config: %w", err)
+	}
+
+	if cfg.API.Listen != "" {
+		return cfg.API.Listen, nil
+	}
+
+	for _, inb := range cfg.Inbounds {
+		if inb.Tag == "" || inb.Tag != cfg.API.Tag {
+			continue
+		}
+
+		port, err := configPort(inb.Port)
+		if err != nil {
+			return "", err
+		}
+
+		host := inb.Listen
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			host = "127.0.0.1"
+		}
+
+		return net.JoinHostPort(host, strconv.Itoa(port)), nil
+	}
+
+	return "", ErrNoAPIInbound
+}
+
+// configPort - inbound port is number or numeric string in Xray config
+func configPort(raw json.RawMessage) (int, error) {
+	var port int
+	if err := json.Unmarshal(raw, &port); err == nil {
+		return port, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return 0, fmt.Errorf("bad api inbound port: %s", raw)
+	}
+
+	return strconv.Atoi(s)
+}
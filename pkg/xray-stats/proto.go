@@ -0,0 +1,235 @@
+package xraystats
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Hand encoded messages of xray.app.stats.command package,
+// so exporter does not depend on Xray generated code.
+// Messages encode both ways, tests serve fake StatsService with the same codec
+// and check it against bytes of Xray generated messages
+
+const (
+	methodQueryStats  = "/xray.app.stats.command.StatsService/QueryStats"
+	methodGetSysStats = "/xray.app.stats.command.StatsService/GetSysStats"
+)
+
+type message interface {
+	marshal() []byte
+	unmarshal(b []byte) error
+}
+
+// codec - grpc codec of hand encoded messages, named proto to match Xray server side
+type codec struct{}
+
+func (codec) Name() string { return "proto" }
+
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("unsupported message type %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (codec) Unmarshal(b []byte, v any) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("unsupported message type %T", v)
+	}
+	return m.unmarshal(b)
+}
+
+// queryStatsRequest - QueryStatsRequest{pattern = 1, reset = 2}
+type queryStatsRequest struct {
+	Pattern string
+	Reset   bool
+}
+
+func (r *queryStatsRequest) marshal() []byte {
+	var b []byte
+	if r.Pattern != "" {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, r.Pattern)
+	}
+	if r.Reset {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	return b
+}
+
+func (r *queryStatsRequest) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, v uint64, raw []byte) {
+		switch num {
+		case 1:
+			r.Pattern = string(raw)
+		case 2:
+			r.Reset = v != 0
+		}
+	})
+}
+
+// Stat - Stat{name = 1, value = 2}
+type Stat struct {
+	Name  string
+	Value int64
+}
+
+func (s *Stat) marshal() []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, s.Name)
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(s.Value))
+	return b
+}
+
+func (s *Stat) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, v uint64, raw []byte) {
+		switch num {
+		case 1:
+			s.Name = string(raw)
+		case 2:
+			s.Value = int64(v)
+		}
+	})
+}
+
+// queryStatsResponse - QueryStatsResponse{repeated stat = 1}
+type queryStatsResponse struct {
+	Stats []Stat
+}
+
+func (r *queryStatsResponse) marshal() []byte {
+	var b []byte
+	for i := range r.Stats {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, r.Stats[i].marshal())
+	}
+	return b
+}
+
+func (r *queryStatsResponse) unmarshal(b []byte) error {
+	var err error
+	walkErr := walk(b, func(num protowire.Number, typ protowire.Type, v uint64, raw []byte) {
+		if num != 1 || err != nil {
+			return
+		}
+		st := Stat{}
+		err = st.unmarshal(raw)
+		r.Stats = append(r.Stats, st)
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+	return err
+}
+
+// sysStatsRequest - empty SysStatsRequest
+type sysStatsRequest struct{}
+
+func (*sysStatsRequest) marshal() []byte        { return nil }
+func (*sysStatsRequest) unmarshal([]byte) error { return nil }
+
+// SysStats - Xray runtime stats, SysStatsResponse
+type SysStats struct {
+	NumGoroutine uint32
+	NumGC        uint32
+	Alloc        uint64
+	TotalAlloc   uint64
+	Sys          uint64
+	Mallocs      uint64
+	Frees        uint64
+	LiveObjects  uint64
+	PauseTotalNs uint64
+	Uptime       uint32
+}
+
+func (s *SysStats) marshal() []byte {
+	var b []byte
+	for i, v := range []uint64{
+		uint64(s.NumGoroutine), uint64(s.NumGC), s.Alloc, s.TotalAlloc, s.Sys,
+		s.Mallocs, s.Frees, s.LiveObjects, s.PauseTotalNs, uint64(s.Uptime),
+	} {
+		if v == 0 {
+			continue
+		}
+		b = protowire.AppendTag(b, protowire.Number(i+1), protowire.VarintType)
+		b = protowire.AppendVarint(b, v)
+	}
+	return b
+}
+
+func (s *SysStats) unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, v uint64, raw []byte) {
+		switch num {
+		case 1:
+			s.NumGoroutine = uint32(v)
+		case 2:
+			s.NumGC = uint32(v)
+		case 3:
+			s.Alloc = v
+		case 4:
+			s.TotalAlloc = v
+		case 5:
+			s.Sys = v
+		case 6:
+			s.Mallocs = v
+		case 7:
+			s.Frees = v
+		case 8:
+			s.LiveObjects = v
+		case 9:
+			s.PauseTotalNs = v
+		case 10:
+			s.Uptime = uint32(v)
+		}
+	})
+}
+
+// walk - calls fn for every varint and length delimited field, other field types are skipped
+func walk(b []byte, fn func(num protowire.Number, typ protowire.Type, v uint64, raw []byte)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, typ, v, nil)
+			b = b[n:]
+
+		case protowire.BytesType:
+			raw, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, typ, 0, raw)
+			b = b[n:]
+
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
+
+func (s SysStats) Goroutines() float64    { return float64(s.NumGoroutine) }
+func (s SysStats) GCCount() float64       { return float64(s.NumGC) }
+func (s SysStats) AllocBytes() float64    { return float64(s.Alloc) }
+func (s SysStats) SysBytes() float64      { return float64(s.Sys) }
+func (s SysStats) Objects() float64       { return float64(s.LiveObjects) }
+func (s SysStats) UptimeSeconds() float64 { return float64(s.Uptime) }
@@ -0,0 +1,196 @@
+package xraystats
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Captured StatsService payloads, encoded by Xray generated messages
+const (
+	// QueryStatsRequest{pattern: "user>>>", reset: true}
+	goldenQueryRequest = "0a07757365723e3e3e1001"
+
+	// QueryStatsResponse of alice downlink 5 GiB and vless-reality inbound uplink 1 MiB
+	goldenQueryResponse = "0a2d0a25757365723e3e3e616c6963654076706e3e3e3e747261666669633e3e3e646f776e6c696e6b1080808080140a" +
+		"300a2a696e626f756e643e3e3e766c6573732d7265616c6974793e3e3e747261666669633e3e3e75706c696e6b10808040"
+
+	// SysStatsResponse with every field set
+	goldenSysStats = "082a10071880808004208080808004288080801030a08d063890bf0540904e48959aef3a5080a305"
+)
+
+// commandFile - messages of Xray app/stats/command/command.proto
+func commandFile(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, msg string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(num),
+			Type:   typ.Enum(),
+			Label:  label.Enum(),
+		}
+		if msg != "" {
+			f.TypeName = proto.String(msg)
+		}
+		return f
+	}
+
+	const (
+		optional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		repeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		str      = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		boolean  = descriptorpb.FieldDescriptorProto_TYPE_BOOL
+		i64      = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		u32      = descriptorpb.FieldDescriptorProto_TYPE_UINT32
+		u64      = descriptorpb.FieldDescriptorProto_TYPE_UINT64
+		msg      = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+
+	sysFields := []*descriptorpb.FieldDescriptorProto{}
+	for i, f := range []struct {
+		name string
+		typ  descriptorpb.FieldDescriptorProto_Type
+	}{
+		{"NumGoroutine", u32}, {"NumGC", u32}, {"Alloc", u64}, {"TotalAlloc", u64}, {"Sys", u64},
+		{"Mallocs", u64}, {"Frees", u64}, {"LiveObjects", u64}, {"PauseTotalNs", u64}, {"Uptime", u32},
+	} {
+		sysFields = append(sysFields, field(f.name, int32(i+1), f.typ, optional, ""))
+	}
+
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("app/stats/command/command.proto"),
+		Package: proto.String("xray.app.stats.command"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Stat"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, str, optional, ""),
+				field("value", 2, i64, optional, ""),
+			}},
+			{Name: proto.String("QueryStatsRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("pattern", 1, str, optional, ""),
+				field("reset", 2, boolean, optional, ""),
+			}},
+			{Name: proto.String("QueryStatsResponse"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("stat", 1, msg, repeated, ".xray.app.stats.command.Stat"),
+			}},
+			{Name: proto.String("SysStatsResponse"), Field: sysFields},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("command.proto descriptor: %v", err)
+	}
+
+	return fd
+}
+
+func golden(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestQueryStatsRequestGolden(t *testing.T) {
+	req := &queryStatsRequest{Pattern: "user>>>", Reset: true}
+	if got, want := req.marshal(), golden(t, goldenQueryRequest); !bytes.Equal(got, want) {
+		t.Errorf("marshal() = %x, want %x", got, want)
+	}
+
+	// protobuf runtime encodes the same message from command.proto schema
+	md := commandFile(t).Messages().ByName("QueryStatsRequest")
+	m := dynamicpb.NewMessage(md)
+	m.Set(md.Fields().ByName("pattern"), protoreflect.ValueOfString("inbound>>>"))
+	m.Set(md.Fields().ByName("reset"), protoreflect.ValueOfBool(false))
+
+	want, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := (&queryStatsRequest{Pattern: "inbound>>>"}).marshal(); !bytes.Equal(got, want) {
+		t.Errorf("marshal() = %x, protobuf runtime = %x", got, want)
+	}
+}
+
+func TestQueryStatsResponseGolden(t *testing.T) {
+	want := []Stat{
+		{Name: "user>>>alice@vpn>>>traffic>>>downlink", Value: 5 << 30},
+		{Name: "inbound>>>vless-reality>>>traffic>>>uplink", Value: 1 << 20},
+	}
+
+	resp := &queryStatsResponse{}
+	if err := resp.unmarshal(golden(t, goldenQueryResponse)); err != nil {
+		t.Fatalf("unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(resp.Stats, want) {
+		t.Errorf("unmarshal() = %+v, want %+v", resp.Stats, want)
+	}
+
+	// payload of protobuf runtime decodes the same, negative int64 included
+	fd := commandFile(t)
+	statDesc := fd.Messages().ByName("Stat")
+	respDesc := fd.Messages().ByName("QueryStatsResponse")
+
+	m := dynamicpb.NewMessage(respDesc)
+	list := m.Mutable(respDesc.Fields().ByName("stat")).List()
+	for _, st := range append(want, Stat{Name: "outbound>>>direct>>>traffic>>>uplink", Value: -1}) {
+		sm := dynamicpb.NewMessage(statDesc)
+		sm.Set(statDesc.Fields().ByName("name"), protoreflect.ValueOfString(st.Name))
+		sm.Set(statDesc.Fields().ByName("value"), protoreflect.ValueOfInt64(st.Value))
+		list.Append(protoreflect.ValueOfMessage(sm))
+	}
+
+	payload, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp = &queryStatsResponse{}
+	if err := resp.unmarshal(payload); err != nil {
+		t.Fatalf("unmarshal() error = %v", err)
+	}
+	if len(resp.Stats) != 3 || !reflect.DeepEqual(resp.Stats[:2], want) || resp.Stats[2].Value != -1 {
+		t.Errorf("unmarshal() = %+v", resp.Stats)
+	}
+
+	if err := (&queryStatsResponse{}).unmarshal([]byte{0x0a, 0x05, 0x01}); err == nil {
+		t.Error("unmarshal() accepted truncated payload")
+	}
+}
+
+func TestSysStatsGolden(t *testing.T) {
+	want := SysStats{
+		NumGoroutine: 42, NumGC: 7, Alloc: 8 << 20, TotalAlloc: 1 << 30, Sys: 32 << 20,
+		Mallocs: 100000, Frees: 90000, LiveObjects: 10000, PauseTotalNs: 123456789, Uptime: 86400,
+	}
+
+	got := SysStats{}
+	if err := got.unmarshal(golden(t, goldenSysStats)); err != nil {
+		t.Fatalf("unmarshal() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("unmarshal() = %+v, want %+v", got, want)
+	}
+
+	md := commandFile(t).Messages().ByName("SysStatsResponse")
+	m := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(golden(t, goldenSysStats), m); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.Get(md.Fields().ByName("Uptime")).Uint(); v != 86400 {
+		t.Errorf("golden payload Uptime by protobuf runtime = %d, want 86400", v)
+	}
+	if v := m.Get(md.Fields().ByName("PauseTotalNs")).Uint(); v != 123456789 {
+		t.Errorf("golden payload PauseTotalNs by protobuf runtime = %d", v)
+	}
+}
@@ -0,0 +1,224 @@
+package xraystats
+
+import (
+	"context"
+	"sync"
+
+	x3uiapi "github.com/eterline/x3ui-exporter/pkg/x3-ui-api"
+)
+
+// UsersInbound - Xray user stats have no inbound, users are reported under this inbound name
+const UsersInbound = "xray"
+
+// apiTag - Xray API inbound, its traffic is exporter own queries
+const apiTag = "api"
+
+// counterKey - kind, name and direction of traffic counter
+type counterKey [3]string
+
+// counter - Xray counter value with offset of values lost on its resets
+type counter struct {
+	last   uint64
+	offset uint64
+}
+
+func (c *counter) value() uint64 {
+	return c.offset + c.last
+}
+
+// Source - panel data source over Xray StatsService.
+// Counters are read without reset, so 3X-UI panel polling the same Xray keeps its own accounting.
+// Counter drops on Xray restart or panel side reset are carried by offsets,
+// traffic between the last read and such drop is not seen
+type Source struct {
+	*Client
+
+	mu       sync.Mutex
+	counters map[counterKey]*counter
+	// pending - inbound and outbound traffic not yet returned by TrafficDeltas
+	pending map[counterKey]uint64
+	// order - counters in order of first appearance, keeps output stable
+	order []counterKey
+}
+
+func NewSource(c *Client) *Source {
+	return &Source{
+		Client:   c,
+		counters: map[counterKey]*counter{},
+		pending:  map[counterKey]uint64{},
+	}
+}
+
+// poll - reads Xray counters, adds their increase since previous read to pending deltas.
+// Must be called under lock
+func (s *Source) poll(ctx context.Context) error {
+	traffic, err := s.Client.Traffic(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range traffic {
+		if t.Kind == KindInbound && t.Name == apiTag {
+			continue
+		}
+
+		dir := statDownlink
+		if t.Uplink {
+			dir = statUplink
+		}
+
+		key := counterKey{t.Kind, t.Name, dir}
+		c, ok := s.counters[key]
+		if !ok {
+			c = &counter{}
+			s.counters[key] = c
+			s.order = append(s.order, key)
+		}
+
+		delta := t.Value - c.last
+		if t.Value < c.last {
+			c.offset += c.last
+			delta = t.Value
+		}
+		c.last = t.Value
+
+		if delta > 0 && (t.Kind == KindInbound || t.Kind == KindOutbound) {
+			s.pending[key] += delta
+		}
+	}
+
+	return nil
+}
+
+// Inbounds - inbounds by tag with their traffic and users under UsersInbound
+func (s *Source) Inbounds(ctx context.Context) ([]x3uiapi.Inbound, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.poll(ctx); err != nil {
+		return nil, err
+	}
+
+	inbounds := []x3uiapi.Inbound{}
+	index := map[string]int{}
+	users := []x3uiapi.ClientStats{}
+	userIndex := map[string]int{}
+
+	for _, key := range s.order {
+		kind, name, uplink := key[0], key[1], key[2] == statUplink
+		value := int64(s.counters[key].value())
+
+		switch kind {
+		case KindInbound:
+			i, ok := index[name]
+			if !ok {
+				i = len(inbounds)
+				index[name] = i
+				inbounds = append(inbounds, x3uiapi.Inbound{Remark: name, Tag: name, Enable: true})
+			}
+			if uplink {
+				inbounds[i].Up = value
+			} else {
+				inbounds[i].Down = value
+			}
+
+		case KindUser:
+			i, ok := userIndex[name]
+			if !ok {
+				i = len(users)
+				userIndex[name] = i
+				users = append(users, x3uiapi.ClientStats{Email: name, Enable: true})
+			}
+			if uplink {
+				users[i].Up = value
+			} else {
+				users[i].Down = value
+			}
+		}
+	}
+
+	if len(users) > 0 {
+		inbounds = append(inbounds, x3uiapi.Inbound{
+			Remark:       UsersInbound,
+			Enable:       true,
+			ClientsStats: users,
+		})
+	}
+
+	return inbounds, nil
+}
+
+// ClientStats - traffic of every Xray user
+func (s *Source) ClientStats(ctx context.Context) ([]x3uiapi.ClientStats, error) {
+	inbounds, err := s.Inbounds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := []x3uiapi.ClientStats{}
+	for _, inb := range inbounds {
+		stats = append(stats, inb.ClientsStats...)
+	}
+
+	return stats, nil
+}
+
+// Online - not exposed by StatsService
+func (s *Source) Online(ctx context.Context) (x3uiapi.Online, error) {
+	return nil, x3uiapi.ErrNotSupported
+}
+
+// ServerStatus - Xray state and uptime from runtime stats
+func (s *Source) ServerStatus(ctx context.Context) (*x3uiapi.ServerStatus, error) {
+	sys, err := s.Client.SysStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &x3uiapi.ServerStatus{Uptime: uint64(sys.Uptime)}
+	status.Xray.State = "running"
+
+	return status, nil
+}
+
+// TrafficDeltas - inbound and outbound traffic increase since previous call,
+// in the same form as panel pushes
+func (s *Source) TrafficDeltas(ctx context.Context) ([]x3uiapi.InboundTraffic, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.poll(ctx); err != nil {
+		return nil, err
+	}
+
+	deltas := []x3uiapi.InboundTraffic{}
+	index := map[[2]string]int{}
+
+	for _, key := range s.order {
+		delta, ok := s.pending[key]
+		if !ok {
+			continue
+		}
+		kind, name := key[0], key[1]
+
+		i, ok := index[[2]string{kind, name}]
+		if !ok {
+			i = len(deltas)
+			index[[2]string{kind, name}] = i
+			deltas = append(deltas, x3uiapi.InboundTraffic{
+				IsInbound:  kind == KindInbound,
+				IsOutbound: kind == KindOutbound,
+				Tag:        name,
+			})
+		}
+
+		if key[2] == statUplink {
+			deltas[i].Up += delta
+		} else {
+			deltas[i].Down += delta
+		}
+	}
+	clear(s.pending)
+
+	return deltas, nil
+}
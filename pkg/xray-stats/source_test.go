@@ -0,0 +1,357 @@
+package xraystats
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	x3uiapi "github.com/eterline/x3ui-exporter/pkg/x3-ui-api"
+	"google.golang.org/grpc"
+)
+
+// fakeStats - Xray StatsService keeping counters like Xray does
+type fakeStats struct {
+	mu       sync.Mutex
+	counters map[string]int64
+	uptime   uint32
+}
+
+func (f *fakeStats) add(name string, v int64) {
+	f.mu.Lock()
+	f.counters[name] += v
+	f.mu.Unlock()
+}
+
+// resetAll - zeroes counters like panel traffic poll or Xray restart does
+func (f *fakeStats) resetAll() {
+	f.mu.Lock()
+	clear(f.counters)
+	f.mu.Unlock()
+}
+
+func (f *fakeStats) get(name string) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counters[name]
+}
+
+func (f *fakeStats) query(req *queryStatsRequest) *queryStatsResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	resp := &queryStatsResponse{}
+	for name, v := range f.counters {
+		if !strings.Contains(name, req.Pattern) {
+			continue
+		}
+		resp.Stats = append(resp.Stats, Stat{Name: name, Value: v})
+		if req.Reset {
+			f.counters[name] = 0
+		}
+	}
+	return resp
+}
+
+// serveFake - starts fake StatsService with hand encoded codec, returns client connected to it
+func serveFake(t *testing.T, f *fakeStats) *Client {
+	t.Helper()
+
+	desc := grpc.ServiceDesc{
+		ServiceName: "xray.app.stats.command.StatsService",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{
+			{
+				MethodName: "QueryStats",
+				Handler: func(_ any, _ context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+					req := &queryStatsRequest{}
+					if err := dec(req); err != nil {
+						return nil, err
+					}
+					return f.query(req), nil
+				},
+			},
+			{
+				MethodName: "GetSysStats",
+				Handler: func(_ any, _ context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+					if err := dec(&sysStatsRequest{}); err != nil {
+						return nil, err
+					}
+					return &SysStats{NumGoroutine: 12, Alloc: 4096, Uptime: f.uptime}, nil
+				},
+			},
+		},
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer(grpc.ForceServerCodec(codec{}))
+	srv.RegisterService(&desc, f)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	c, err := NewClient(lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+func newFake() *fakeStats {
+	return &fakeStats{counters: map[string]int64{}, uptime: 3600}
+}
+
+func TestQueryStatsReset(t *testing.T) {
+	f := newFake()
+	f.add("user>>>alice>>>traffic>>>uplink", 100)
+	f.add("inbound>>>vless>>>traffic>>>downlink", 300)
+	c := serveFake(t, f)
+
+	stats, err := c.QueryStats(context.Background(), "user>>>", false)
+	if err != nil {
+		t.Fatalf("QueryStats() error = %v", err)
+	}
+	if !reflect.DeepEqual(stats, []Stat{{Name: "user>>>alice>>>traffic>>>uplink", Value: 100}}) {
+		t.Errorf("QueryStats() = %v", stats)
+	}
+
+	if _, err := c.QueryStats(context.Background(), "", true); err != nil {
+		t.Fatalf("QueryStats() error = %v", err)
+	}
+	for name, v := range f.counters {
+		if v != 0 {
+			t.Errorf("counter %s = %d after reset query, want 0", name, v)
+		}
+	}
+}
+
+func TestSourceAccumulates(t *testing.T) {
+	f := newFake()
+	c := serveFake(t, f)
+	src := NewSource(c)
+	ctx := context.Background()
+
+	f.add("user>>>alice>>>traffic>>>uplink", 100)
+	f.add("user>>>alice>>>traffic>>>downlink", 1000)
+	f.add("inbound>>>vless>>>traffic>>>uplink", 100)
+	f.add("inbound>>>vless>>>traffic>>>downlink", 1000)
+	f.add("inbound>>>api>>>traffic>>>downlink", 50)
+	f.add("outbound>>>direct>>>traffic>>>downlink", 700)
+
+	if _, err := src.Inbounds(ctx); err != nil {
+		t.Fatalf("Inbounds() error = %v", err)
+	}
+
+	// traffic after previous poll, counters keep growing as they are read without reset
+	f.add("user>>>alice>>>traffic>>>uplink", 20)
+	f.add("user>>>bob>>>traffic>>>downlink", 5)
+	f.add("inbound>>>vless>>>traffic>>>uplink", 25)
+
+	inbounds, err := src.Inbounds(ctx)
+	if err != nil {
+		t.Fatalf("Inbounds() error = %v", err)
+	}
+
+	want := []x3uiapi.Inbound{
+		{Remark: "vless", Tag: "vless", Enable: true, Up: 125, Down: 1000},
+		{Remark: UsersInbound, Enable: true, ClientsStats: []x3uiapi.ClientStats{
+			{Email: "alice", Enable: true, Up: 120, Down: 1000},
+			{Email: "bob", Enable: true, Down: 5},
+		}},
+	}
+
+	// Xray answers in map order, users are compared regardless of it
+	if len(inbounds) != 2 || !reflect.DeepEqual(inbounds[0], want[0]) {
+		t.Fatalf("Inbounds() = %+v, want %+v", inbounds, want)
+	}
+	users := map[string]x3uiapi.ClientStats{}
+	for _, u := range inbounds[1].ClientsStats {
+		users[u.Email] = u
+	}
+	for _, u := range want[1].ClientsStats {
+		if users[u.Email] != u {
+			t.Errorf("Inbounds() user %s = %+v, want %+v", u.Email, users[u.Email], u)
+		}
+	}
+
+	stats, err := src.ClientStats(ctx)
+	if err != nil || len(stats) != 2 {
+		t.Errorf("ClientStats() = %+v, %v", stats, err)
+	}
+}
+
+func TestSourceTrafficDeltas(t *testing.T) {
+	f := newFake()
+	c := serveFake(t, f)
+	src := NewSource(c)
+	ctx := context.Background()
+
+	f.add("inbound>>>vless>>>traffic>>>uplink", 100)
+	f.add("inbound>>>api>>>traffic>>>uplink", 100)
+	f.add("outbound>>>direct>>>traffic>>>downlink", 700)
+
+	deltas, err := src.TrafficDeltas(ctx)
+	if err != nil {
+		t.Fatalf("TrafficDeltas() error = %v", err)
+	}
+	if got := byTag(deltas); !reflect.DeepEqual(got, map[string]x3uiapi.InboundTraffic{
+		"vless":  {IsInbound: true, Tag: "vless", Up: 100},
+		"direct": {IsOutbound: true, Tag: "direct", Down: 700},
+	}) {
+		t.Errorf("TrafficDeltas() = %+v", got)
+	}
+
+	// traffic read by Inbounds poll in between is still reported by next TrafficDeltas
+	f.add("inbound>>>vless>>>traffic>>>uplink", 10)
+	if _, err := src.Inbounds(ctx); err != nil {
+		t.Fatal(err)
+	}
+	f.add("inbound>>>vless>>>traffic>>>uplink", 5)
+
+	deltas, err = src.TrafficDeltas(ctx)
+	if err != nil {
+		t.Fatalf("TrafficDeltas() error = %v", err)
+	}
+	if got := byTag(deltas); !reflect.DeepEqual(got, map[string]x3uiapi.InboundTraffic{
+		"vless": {IsInbound: true, Tag: "vless", Up: 15},
+	}) {
+		t.Errorf("TrafficDeltas() = %+v", got)
+	}
+
+	deltas, err = src.TrafficDeltas(ctx)
+	if err != nil || len(deltas) != 0 {
+		t.Errorf("TrafficDeltas() without traffic = %+v, %v", deltas, err)
+	}
+}
+
+func TestSourceCounterDrops(t *testing.T) {
+	f := newFake()
+	c := serveFake(t, f)
+	src := NewSource(c)
+	ctx := context.Background()
+
+	f.add("user>>>alice>>>traffic>>>uplink", 100)
+	f.add("inbound>>>vless>>>traffic>>>uplink", 100)
+
+	if _, err := src.Inbounds(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v := f.get("user>>>alice>>>traffic>>>uplink"); v != 100 {
+		t.Fatalf("counter after source read = %d, want 100 left for panel", v)
+	}
+
+	// panel traffic poll resets counters, then more traffic comes
+	f.add("user>>>alice>>>traffic>>>uplink", 30)
+	f.add("inbound>>>vless>>>traffic>>>uplink", 30)
+	if _, err := src.TrafficDeltas(ctx); err != nil {
+		t.Fatal(err)
+	}
+	f.resetAll()
+	f.add("user>>>alice>>>traffic>>>uplink", 20)
+	f.add("inbound>>>vless>>>traffic>>>uplink", 20)
+
+	// Xray restart between reads
+	if _, err := src.Inbounds(ctx); err != nil {
+		t.Fatal(err)
+	}
+	f.resetAll()
+	f.add("user>>>alice>>>traffic>>>uplink", 5)
+	f.add("inbound>>>vless>>>traffic>>>uplink", 5)
+
+	inbounds, err := src.Inbounds(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inbounds) != 2 || inbounds[0].Up != 155 || inbounds[1].ClientsStats[0].Up != 155 {
+		t.Errorf("Inbounds() after counter drops = %+v, want monotonic 155", inbounds)
+	}
+
+	deltas, err := src.TrafficDeltas(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := byTag(deltas); !reflect.DeepEqual(got, map[string]x3uiapi.InboundTraffic{
+		"vless": {IsInbound: true, Tag: "vless", Up: 25},
+	}) {
+		t.Errorf("TrafficDeltas() after counter drops = %+v", got)
+	}
+}
+
+func byTag(deltas []x3uiapi.InboundTraffic) map[string]x3uiapi.InboundTraffic {
+	m := map[string]x3uiapi.InboundTraffic{}
+	for _, d := range deltas {
+		m[d.Tag] = d
+	}
+	return m
+}
+
+func TestSourceStatus(t *testing.T) {
+	c := serveFake(t, newFake())
+	src := NewSource(c)
+
+	sys, err := src.SysStats(context.Background())
+	if err != nil {
+		t.Fatalf("SysStats() error = %v", err)
+	}
+	if sys.NumGoroutine != 12 || sys.Alloc != 4096 || sys.Uptime != 3600 {
+		t.Errorf("SysStats() = %+v", sys)
+	}
+
+	status, err := src.ServerStatus(context.Background())
+	if err != nil || status.Uptime != 3600 || status.Xray.State != "running" {
+		t.Errorf("ServerStatus() = %+v, %v", status, err)
+	}
+
+	if _, err := src.Online(context.Background()); !errors.Is(err, x3uiapi.ErrNotSupported) {
+		t.Errorf("Online() error = %v, want %v", err, x3uiapi.ErrNotSupported)
+	}
+}
+
+func TestSourceUnavailable(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	c, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := NewSource(c).Inbounds(context.Background()); !errors.Is(err, ErrXrayAPI) {
+		t.Errorf("Inbounds() error = %v, want %v", err, ErrXrayAPI)
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	tests := []struct {
+		name   string
+		kind   string
+		object string
+		uplink bool
+		ok     bool
+	}{
+		{"user>>>alice@vpn>>>traffic>>>uplink", KindUser, "alice@vpn", true, true},
+		{"outbound>>>direct>>>traffic>>>downlink", KindOutbound, "direct", false, true},
+		{"user>>>alice>>>online", "", "", false, false},
+		{"inbound>>>vless>>>traffic>>>sideways", "", "", false, false},
+	}
+
+	for _, tt := range tests {
+		kind, object, uplink, ok := ParseStat(tt.name)
+		if kind != tt.kind || object != tt.object || uplink != tt.uplink || ok != tt.ok {
+			t.Errorf("ParseStat(%q) = %q, %q, %v, %v", tt.name, kind, object, uplink, ok)
+		}
+	}
+}